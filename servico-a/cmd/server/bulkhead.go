@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// bulkhead caps the number of requests handled concurrently, so a traffic
+// spike cannot open an unbounded number of calls to servico-b.
+type bulkhead struct {
+	slots chan struct{}
+}
+
+// newBulkhead allows maxInflight concurrent requests; zero or less disables
+// the limit.
+func newBulkhead(maxInflight int) *bulkhead {
+	if maxInflight <= 0 {
+		return &bulkhead{}
+	}
+	return &bulkhead{slots: make(chan struct{}, maxInflight)}
+}
+
+// Middleware answers 503 when every slot is taken instead of queueing. The
+// slot is released in a deferred call, so a panic recovered further up the
+// chain does not leak it.
+func (b *bulkhead) Middleware(next http.Handler) http.Handler {
+	if b.slots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case b.slots <- struct{}{}:
+		default:
+			trace.SpanFromContext(r.Context()).AddEvent("bulkhead.rejected", trace.WithAttributes(
+				attribute.Int("bulkhead.max_inflight", cap(b.slots)),
+			))
+			writeJSON(r.Context(), w, http.StatusServiceUnavailable, map[string]string{"error": "server busy"})
+			return
+		}
+		defer func() { <-b.slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
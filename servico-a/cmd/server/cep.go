@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reasons returned by validateCEP.
+var (
+	errCEPLength   = errors.New("cep must have exactly 8 digits")
+	errCEPNonDigit = errors.New("cep must contain only digits")
+	errCEPRange    = errors.New("cep is outside the assigned range")
+)
+
+// normalizeCEP strips the formatting clients commonly send along with the
+// digits, such as surrounding spaces or the "01310-100" hyphen.
+func normalizeCEP(cep string) string {
+	return strings.Replace(strings.TrimSpace(cep), "-", "", 1)
+}
+
+// validateCEP reports why cep is not a valid CEP, or nil when it is.
+func validateCEP(cep string) error {
+	if len(cep) != 8 {
+		return errCEPLength
+	}
+	for _, char := range cep {
+		if char < '0' || char > '9' {
+			return errCEPNonDigit
+		}
+	}
+	// Cheap pre-check before any upstream round trip: no CEP is assigned
+	// below 01000-000, so this also rejects "00000000".
+	if strings.HasPrefix(cep, "00") {
+		return errCEPRange
+	}
+	return nil
+}
+
+// CEPValue is a CEP sent either as a JSON string or as a JSON number. Numbers
+// lose their leading zeros (01310100 arrives as 1310100), so they are
+// left-padded back to 8 digits and Padded is set.
+type CEPValue struct {
+	Value  string
+	Padded bool
+}
+
+func (c *CEPValue) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.Value)
+	}
+	if string(data) == "null" {
+		return nil
+	}
+
+	digits := string(data)
+	for _, char := range digits {
+		if char < '0' || char > '9' {
+			return fmt.Errorf("cep must be a string or a non-negative integer, got %s", digits)
+		}
+	}
+	if len(digits) > 8 {
+		return fmt.Errorf("cep must have at most 8 digits, got %s", digits)
+	}
+
+	c.Value = strings.Repeat("0", 8-len(digits)) + digits
+	c.Padded = len(digits) < 8
+	return nil
+}
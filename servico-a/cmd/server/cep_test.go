@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var cepValidationTests = []struct {
+	name           string
+	input          string
+	wantNormalized string
+	wantErr        error
+}{
+	{"valid", "01310100", "01310100", nil},
+	{"hyphenated", "01310-100", "01310100", nil},
+	{"too short", "123", "123", errCEPLength},
+	{"too long", "013101000", "013101000", errCEPLength},
+	{"non-digit", "0131010a", "0131010a", errCEPNonDigit},
+	{"outside the assigned range", "00999999", "00999999", errCEPRange},
+}
+
+func TestValidateCEP(t *testing.T) {
+	for _, tt := range cepValidationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cep := normalizeCEP(tt.input)
+			if cep != tt.wantNormalized {
+				t.Errorf("normalizeCEP(%q) = %q, want %q", tt.input, cep, tt.wantNormalized)
+			}
+			if err := validateCEP(cep); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateCEP(%q) = %v, want %v", cep, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleValidateCEP(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get("/cep/{cep}/validate", handleValidateCEP)
+
+	for _, tt := range cepValidationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cep/"+tt.input+"/validate", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var got CEPValidationResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Valid != (tt.wantErr == nil) {
+				t.Errorf("valid = %t, want %t", got.Valid, tt.wantErr == nil)
+			}
+			if got.Normalized != tt.wantNormalized {
+				t.Errorf("normalized = %q, want %q", got.Normalized, tt.wantNormalized)
+			}
+			switch {
+			case tt.wantErr == nil && !strings.Contains(rec.Body.String(), `"reason":null`):
+				t.Errorf("body = %s, want an explicit null reason", rec.Body)
+			case tt.wantErr != nil && (got.Reason == nil || *got.Reason != tt.wantErr.Error()):
+				t.Errorf("reason = %v, want %q", got.Reason, tt.wantErr.Error())
+			}
+		})
+	}
+}
+
+func TestHandleValidateOnly(t *testing.T) {
+	router := chi.NewRouter()
+	router.Post("/temperature/validate", handleValidateOnly)
+
+	for _, tt := range cepValidationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/temperature/validate", strings.NewReader(`{"cep": "`+tt.input+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			wantStatus := http.StatusOK
+			if tt.wantErr != nil {
+				wantStatus = http.StatusUnprocessableEntity
+			}
+			if rec.Code != wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, wantStatus, rec.Body)
+			}
+
+			var got CEPValidationResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Valid != (tt.wantErr == nil) {
+				t.Errorf("valid = %t, want %t", got.Valid, tt.wantErr == nil)
+			}
+			if got.Normalized != tt.wantNormalized {
+				t.Errorf("normalized = %q, want %q", got.Normalized, tt.wantNormalized)
+			}
+			switch {
+			case tt.wantErr == nil && !strings.Contains(rec.Body.String(), `"reason":null`):
+				t.Errorf("body = %s, want an explicit null reason", rec.Body)
+			case tt.wantErr != nil && (got.Reason == nil || *got.Reason != tt.wantErr.Error()):
+				t.Errorf("reason = %v, want %q", got.Reason, tt.wantErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCityLength bounds the city names accepted by POST /temperature/city.
+const maxCityLength = 100
+
+// Reasons returned by validateCity.
+var (
+	errCityEmpty      = errors.New("city is required")
+	errCityLength     = fmt.Errorf("city must have at most %d characters", maxCityLength)
+	errCityCharacters = errors.New("city may only contain letters, spaces, hyphens, apostrophes and dots")
+)
+
+type CityRequest struct {
+	City string `json:"city"`
+}
+
+// normalizeCity trims the name and collapses runs of whitespace.
+func normalizeCity(city string) string {
+	return strings.Join(strings.Fields(city), " ")
+}
+
+// validateCity reports why city cannot be sent to the weather provider, or
+// nil when it can. Only characters found in place names are allowed, so the
+// name never carries URL syntax such as "/", "?" or "&".
+func validateCity(city string) error {
+	if city == "" {
+		return errCityEmpty
+	}
+	if len([]rune(city)) > maxCityLength {
+		return errCityLength
+	}
+	for _, char := range city {
+		switch {
+		case unicode.IsLetter(char), unicode.Is(unicode.Mn, char):
+		case char == ' ', char == '-', char == '\'', char == '.':
+		default:
+			return errCityCharacters
+		}
+	}
+	return nil
+}
+
+// handleCity answers POST /temperature/city for clients that know the city
+// but not a CEP. servico-b skips ViaCEP and looks the weather up by name;
+// the response is a CEPResponse with the city echoed back.
+func handleCity(w http.ResponseWriter, r *http.Request) {
+	tracer := otel.Tracer("servico-a")
+	ctx, span := tracer.Start(r.Context(), "servico-a.handleCity",
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
+	defer span.End()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var req CityRequest
+	if err := decoder.Decode(&req); err != nil {
+		span.RecordError(err)
+		writeNegotiated(ctx, w, r, http.StatusBadRequest, ErrorResponse{Error: decodeErrorMessage(err)})
+		return
+	}
+
+	city := normalizeCity(req.City)
+	if err := validateCity(city); err != nil {
+		span.RecordError(err)
+		writeNegotiated(ctx, w, r, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.String("geo.locality", city))
+
+	ctx, callSpan := tracer.Start(ctx, "servico-a.callServicoB")
+	defer callSpan.End()
+
+	body, err := json.Marshal(CityRequest{City: city})
+	if err != nil {
+		callSpan.RecordError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	targetURL := servicoBURL.JoinPath("temperature", "city")
+	targetURL.RawQuery = forwardedOptions(r).Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", targetURL.String(), bytes.NewReader(body))
+	if err != nil {
+		callSpan.RecordError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	relayServicoB(ctx, w, r, httpReq)
+}
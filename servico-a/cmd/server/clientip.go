@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a list of CIDRs or single IPs.
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// trustedClientIP replaces RemoteAddr with the client address taken from
+// X-Forwarded-For, but only through hops that are trusted proxies: the chain
+// is walked from the nearest hop backwards and the first untrusted address
+// is the client. Requests whose peer is not a trusted proxy keep RemoteAddr.
+func trustedClientIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(ip net.IP) bool {
+		for _, ipNet := range trusted {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(client); err == nil {
+				client = host
+			}
+
+			if ip := net.ParseIP(client); ip != nil && isTrusted(ip) {
+				hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					hop := net.ParseIP(strings.TrimSpace(hops[i]))
+					if hop == nil {
+						break
+					}
+					client = hop.String()
+					if !isTrusted(hop) {
+						break
+					}
+				}
+			}
+
+			r.RemoteAddr = client
+			next.ServeHTTP(w, r)
+		})
+	}
+}
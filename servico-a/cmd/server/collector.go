@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// defaultCollectorPort is used for an http(s):// endpoint without a port.
+const defaultCollectorPort = "4317"
+
+// parseCollectorEndpoints turns the OTEL_EXPORTER_OTLP_ENDPOINT entries into
+// gRPC dial targets. An entry may be a bare host:port or, as the OpenTelemetry
+// spec allows, an http:// or https:// URL; the scheme is stripped and
+// returned so it can pick the transport security. The path of a URL is
+// ignored, since OTLP/gRPC does not use one. All entries must agree on the
+// scheme, as one set of credentials is shared by every endpoint.
+func parseCollectorEndpoints(endpoints []string) (targets []string, scheme string, err error) {
+	targets = make([]string, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		target, endpointScheme := endpoint, ""
+		if strings.Contains(endpoint, "://") {
+			u, err := url.Parse(endpoint)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid collector endpoint %q: %w", endpoint, err)
+			}
+			endpointScheme = strings.ToLower(u.Scheme)
+			if endpointScheme != "http" && endpointScheme != "https" {
+				return nil, "", fmt.Errorf("invalid collector endpoint %q: scheme must be http or https", endpoint)
+			}
+			if u.Hostname() == "" {
+				return nil, "", fmt.Errorf("invalid collector endpoint %q: missing host", endpoint)
+			}
+			port := u.Port()
+			if port == "" {
+				port = defaultCollectorPort
+			}
+			target = net.JoinHostPort(u.Hostname(), port)
+		}
+		if i > 0 && endpointScheme != scheme {
+			return nil, "", fmt.Errorf("collector endpoints mix schemes: %q and %q", endpoints[0], endpoint)
+		}
+		scheme = endpointScheme
+		targets = append(targets, target)
+	}
+	return targets, scheme, nil
+}
+
+// collectorCredentials builds the transport credentials for the collector
+// connection. An http:// endpoint scheme means plaintext and https:// means
+// TLS; for bare host:port endpoints OTEL_EXPORTER_OTLP_INSECURE decides and
+// defaults to true. TLS uses the CA in OTEL_EXPORTER_OTLP_CERTIFICATE (system
+// roots when unset) and, for mTLS, the client pair in
+// OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and OTEL_EXPORTER_OTLP_CLIENT_KEY.
+func collectorCredentials(scheme string) (credentials.TransportCredentials, error) {
+	insecureConn := true
+	switch scheme {
+	case "http":
+	case "https":
+		insecureConn = false
+	default:
+		if value := os.Getenv("OTEL_EXPORTER_OTLP_INSECURE"); value != "" {
+			var err error
+			insecureConn, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_INSECURE %q: %w", value, err)
+			}
+		}
+	}
+	if insecureConn {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile := os.Getenv("OTEL_EXPORTER_OTLP_CERTIFICATE"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read collector CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in collector CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile := os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE")
+	keyFile := os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_KEY")
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and OTEL_EXPORTER_OTLP_CLIENT_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load collector client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// dialCollector connects to the first reachable collector. Each attempt
+// tries the endpoints in order; after a full pass without success it waits
+// and starts over, up to 20 attempts or until ctx is cancelled.
+//
+// When blocking is false it returns at once with a connection to the first
+// endpoint that is established lazily in the background; spans are buffered
+// by the batch processor, and dropped once it is full, until the collector
+// is reachable. The other endpoints are not used in that mode.
+func dialCollector(ctx context.Context, endpoints []string, creds credentials.TransportCredentials, blocking bool) (*grpc.ClientConn, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no OTEL collector endpoint configured")
+	}
+
+	if !blocking {
+		slog.Info("Connecting to OTEL collector in the background", "dial_mode", "non-blocking", "endpoint", endpoints[0])
+		if len(endpoints) > 1 {
+			slog.Warn("Non-blocking dial only uses the first collector endpoint", "ignored", endpoints[1:])
+		}
+		conn, err := grpc.NewClient(endpoints[0], grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gRPC client for collector %s: %w", endpoints[0], err)
+		}
+		return conn, nil
+	}
+	slog.Info("Connecting to OTEL collector", "dial_mode", "blocking", "endpoints", endpoints)
+
+	maxRetries := 20
+	retryDelay := 2 * time.Second
+
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		for _, endpoint := range endpoints {
+			dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			var conn *grpc.ClientConn
+			conn, err = grpc.DialContext(dialCtx, endpoint,
+				grpc.WithTransportCredentials(creds),
+				grpc.WithBlock(),
+			)
+			cancel()
+
+			if err == nil {
+				slog.Info("Successfully connected to OTEL collector", "endpoint", endpoint, "attempts", i+1)
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if len(endpoints) > 1 {
+				slog.Warn("Failed to connect to collector endpoint", "endpoint", endpoint, "error", err)
+			}
+		}
+
+		if i < maxRetries-1 {
+			slog.Warn("Failed to connect to collector, retrying",
+				"attempt", i+1, "max_attempts", maxRetries, "retry_in", retryDelay, "error", err)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return nil, fmt.Errorf("failed to create gRPC connection to collector after %d attempts: %w", maxRetries, err)
+}
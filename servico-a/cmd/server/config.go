@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the startup settings of servico-a. Values come from the
+// defaults below, then from the YAML or JSON file named by CONFIG_FILE, and
+// finally from the environment variables, which take precedence.
+type Config struct {
+	ServiceName              string        `yaml:"service_name"`
+	CollectorEndpoints       []string      `yaml:"collector_endpoints"`
+	SDKDisabled              bool          `yaml:"sdk_disabled"`
+	DialBlocking             bool          `yaml:"dial_blocking"`
+	TraceContextHeader       string        `yaml:"trace_context_header"`
+	Propagators              []string      `yaml:"propagators"`
+	TraceSampleRatio         float64       `yaml:"trace_sample_ratio"`
+	TraceIgnoreRoutes        []string      `yaml:"trace_ignore_routes"`
+	TraceShutdownTimeout     time.Duration `yaml:"trace_shutdown_timeout"`
+	BSPMaxQueueSize          int           `yaml:"bsp_max_queue_size"`
+	BSPMaxExportBatchSize    int           `yaml:"bsp_max_export_batch_size"`
+	BSPExportTimeout         time.Duration `yaml:"bsp_export_timeout"`
+	BSPScheduleDelay         time.Duration `yaml:"bsp_schedule_delay"`
+	HTTPPort                 string        `yaml:"http_port"`
+	HTTPAddr                 string        `yaml:"http_addr"`
+	ServicoBURL              string        `yaml:"servico_b_url"`
+	MaxBodyBytes             int64         `yaml:"max_body_bytes"`
+	UpstreamMaxConnsPerHost  int64         `yaml:"upstream_max_conns_per_host"`
+	UpstreamErrorSnippet     int           `yaml:"upstream_error_snippet"`
+	CompressMinSize          int           `yaml:"compress_min_size"`
+	UpstreamMaxResponseBytes int64         `yaml:"upstream_max_response_bytes"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		ServiceName:              defaultServiceName("servico-a"),
+		Propagators:              []string{"tracecontext", "baggage"},
+		CollectorEndpoints:       []string{"otel-collector:4317"},
+		DialBlocking:             true,
+		TraceSampleRatio:         1,
+		TraceShutdownTimeout:     5 * time.Second,
+		BSPMaxQueueSize:          sdktrace.DefaultMaxQueueSize,
+		BSPMaxExportBatchSize:    sdktrace.DefaultMaxExportBatchSize,
+		BSPExportTimeout:         sdktrace.DefaultExportTimeout * time.Millisecond,
+		BSPScheduleDelay:         sdktrace.DefaultScheduleDelay * time.Millisecond,
+		HTTPPort:                 ":8080",
+		ServicoBURL:              "http://servico-b:8081",
+		MaxBodyBytes:             defaultMaxBodyBytes,
+		UpstreamErrorSnippet:     defaultUpstreamErrorSnippet,
+		CompressMinSize:          defaultCompressMinSize,
+		UpstreamMaxResponseBytes: defaultMaxUpstreamResponseBytes,
+	}
+}
+
+// defaultServiceName derives the service name from the binary name, so a
+// binary reused under another name reports traces under that name. The
+// names produced by "go run" and by a plain "go build ./cmd/server" ("main"
+// and "server") say nothing about the service, so they fall back to
+// fallback, as does an empty name.
+func defaultServiceName(fallback string) string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	switch name {
+	case "", ".", string(filepath.Separator), "main", "server":
+		return fallback
+	}
+	return name
+}
+
+// loadConfig builds the Config, failing only when CONFIG_FILE is set but
+// cannot be read or parsed.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+		}
+		// JSON is valid YAML, so one decoder handles both formats.
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+		}
+	}
+
+	cfg.ServiceName = getEnv("OTEL_SERVICE_NAME", cfg.ServiceName)
+	cfg.CollectorEndpoints = getEnvList("OTEL_EXPORTER_OTLP_ENDPOINT", cfg.CollectorEndpoints)
+	if value := os.Getenv("OTEL_SDK_DISABLED"); value != "" {
+		cfg.SDKDisabled = value == "true"
+	}
+	if value := os.Getenv("OTEL_DIAL_BLOCKING"); value != "" {
+		cfg.DialBlocking = value == "true"
+	}
+	cfg.TraceContextHeader = getEnv("TRACE_CONTEXT_HEADER", cfg.TraceContextHeader)
+	cfg.TraceSampleRatio = math.Min(math.Max(getEnvFloat("TRACE_SAMPLE_RATIO", cfg.TraceSampleRatio), 0), 1)
+	cfg.TraceIgnoreRoutes = getEnvList("TRACE_IGNORE_ROUTES", cfg.TraceIgnoreRoutes)
+	cfg.Propagators = getEnvList("OTEL_PROPAGATORS", cfg.Propagators)
+	for _, name := range cfg.Propagators {
+		if _, ok := propagators[name]; !ok {
+			return Config{}, fmt.Errorf("unknown propagator %q in OTEL_PROPAGATORS", name)
+		}
+	}
+	cfg.TraceShutdownTimeout = getEnvDuration("TRACE_SHUTDOWN_TIMEOUT", cfg.TraceShutdownTimeout)
+	cfg.BSPMaxQueueSize = max(int(getEnvInt64("OTEL_BSP_MAX_QUEUE_SIZE", int64(cfg.BSPMaxQueueSize))), 1)
+	// The SDK would silently cap the batch at the queue size anyway.
+	cfg.BSPMaxExportBatchSize = min(max(int(getEnvInt64("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", int64(cfg.BSPMaxExportBatchSize))), 1), cfg.BSPMaxQueueSize)
+	cfg.BSPExportTimeout = getEnvMillis("OTEL_BSP_EXPORT_TIMEOUT", cfg.BSPExportTimeout)
+	cfg.BSPScheduleDelay = getEnvMillis("OTEL_BSP_SCHEDULE_DELAY", cfg.BSPScheduleDelay)
+	cfg.HTTPPort = getEnv("HTTP_PORT", cfg.HTTPPort)
+	// HTTP_ADDR binds to a specific interface; without it the service
+	// listens on HTTP_PORT on all interfaces.
+	cfg.HTTPAddr = getEnv("HTTP_ADDR", cfg.HTTPAddr)
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = cfg.HTTPPort
+	}
+	if err := validateListenAddr(cfg.HTTPAddr); err != nil {
+		return Config{}, err
+	}
+	cfg.ServicoBURL = strings.TrimSuffix(getEnv("SERVICO_B_URL", cfg.ServicoBURL), "/")
+	cfg.MaxBodyBytes = getEnvInt64("MAX_BODY_BYTES", cfg.MaxBodyBytes)
+	cfg.UpstreamMaxConnsPerHost = getEnvInt64("UPSTREAM_MAX_CONNS_PER_HOST", cfg.UpstreamMaxConnsPerHost)
+	cfg.UpstreamErrorSnippet = max(int(getEnvInt64("UPSTREAM_ERROR_SNIPPET", int64(cfg.UpstreamErrorSnippet))), 0)
+	cfg.CompressMinSize = max(int(getEnvInt64("COMPRESS_MIN_SIZE", int64(cfg.CompressMinSize))), 0)
+	cfg.UpstreamMaxResponseBytes = max(getEnvInt64("UPSTREAM_MAX_RESPONSE_BYTES", cfg.UpstreamMaxResponseBytes), 1)
+
+	return cfg, nil
+}
+
+// validateListenAddr checks that addr is a "host:port" (or ":port") address
+// net/http can listen on.
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port in listen address %q", addr)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/credentials"
+)
+
+// DebugConfigResponse is the effective tracing configuration served by
+// GET /debug/config. It must never carry secrets.
+type DebugConfigResponse struct {
+	ServiceName        string         `json:"service_name"`
+	Version            string         `json:"version"`
+	SDKDisabled        bool           `json:"sdk_disabled"`
+	Exporter           ExporterConfig `json:"exporter"`
+	Sampler            string         `json:"sampler"`
+	PropagationHeaders []string       `json:"propagation_headers"`
+}
+
+type ExporterConfig struct {
+	Protocol  string   `json:"protocol"`
+	Endpoints []string `json:"endpoints"`
+	Security  string   `json:"security"`
+}
+
+// debugConfigHandler serves the tracing configuration of cfg. It is only
+// routed when DEBUG_ENDPOINTS=true.
+func debugConfigHandler(cfg Config, creds credentials.TransportCredentials) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sampler := "disabled"
+		if !cfg.SDKDisabled {
+			sampler = newSampler(cfg.TraceSampleRatio, cfg.TraceIgnoreRoutes).Description()
+		}
+		writeJSON(r.Context(), w, http.StatusOK, DebugConfigResponse{
+			ServiceName: cfg.ServiceName,
+			Version:     version,
+			SDKDisabled: cfg.SDKDisabled,
+			Exporter: ExporterConfig{
+				Protocol:  "grpc",
+				Endpoints: cfg.CollectorEndpoints,
+				Security:  creds.Info().SecurityProtocol,
+			},
+			Sampler: sampler,
+			// The headers the active propagators read and write identify
+			// them (traceparent for W3C, baggage, a custom header).
+			PropagationHeaders: otel.GetTextMapPropagator().Fields(),
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// forceTraceBaggageKey is the baggage member that asks every service on the
+// request path to sample the trace.
+const forceTraceBaggageKey = "debug.force_trace"
+
+// forceTraceEnabled lets clients force sampling with "X-Force-Trace: true".
+// It is off by default: anyone who can reach servico-a could otherwise make
+// every request exported, bypassing TRACE_SAMPLE_RATIO.
+var forceTraceEnabled bool
+
+type forceSampleKey struct{}
+
+// withForceSample marks ctx so that spans started from it are always sampled,
+// regardless of the configured ratio.
+func withForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleKey{}, true)
+}
+
+// forceSampled reports whether ctx was marked by withForceSample or carries
+// the force-trace baggage member added by forceTraceMiddleware.
+func forceSampled(ctx context.Context) bool {
+	if forced, _ := ctx.Value(forceSampleKey{}).(bool); forced {
+		return true
+	}
+	return baggage.FromContext(ctx).Member(forceTraceBaggageKey).Value() == "true"
+}
+
+// forceTraceMiddleware turns "X-Force-Trace: true" into the force-trace
+// baggage member, which the sampler consults and the propagator forwards to
+// servico-b. It edits the incoming baggage header, since that is what the
+// server span's context is extracted from, and it always drops a member the
+// client sent itself, so only the header, and only when forceTraceEnabled is
+// set, can force sampling.
+func forceTraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		force := forceTraceEnabled && r.Header.Get("X-Force-Trace") == "true"
+		header := r.Header.Get("baggage")
+		if !force && header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bag, err := baggage.Parse(header)
+		if err != nil {
+			// The propagator would discard the malformed header anyway.
+			bag = baggage.Baggage{}
+		}
+		bag = bag.DeleteMember(forceTraceBaggageKey)
+		if force {
+			member, _ := baggage.NewMember(forceTraceBaggageKey, "true")
+			bag, _ = bag.SetMember(member)
+		}
+
+		if value := bag.String(); value != "" {
+			r.Header.Set("baggage", value)
+		} else {
+			r.Header.Del("baggage")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// debugSamplingMiddleware peeks at the CEP in POST / bodies before the server
+// span is started and forces sampling when the CEP is in debugCEPs.
+// The bytes read are put back in front of the body, so the handler still
+// sees the full request and still enforces MAX_BODY_BYTES.
+func debugSamplingMiddleware(debugCEPs []string) func(http.Handler) http.Handler {
+	ceps := make(map[string]bool, len(debugCEPs))
+	for _, cep := range debugCEPs {
+		ceps[normalizeCEP(cep)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(ceps) == 0 || r.Method != http.MethodPost || r.URL.Path != "/" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			peeked, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var req struct {
+				CEP CEPValue `json:"cep"`
+			}
+			if json.Unmarshal(peeked, &req) == nil && ceps[normalizeCEP(req.CEP.Value)] {
+				r = r.WithContext(withForceSample(r.Context()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider using sampler as the global one for
+// the duration of the test and returns the recorder of its sampled spans.
+func recordSpans(t *testing.T, sampler sdktrace.Sampler) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestDebugCEPIsAlwaysSampled(t *testing.T) {
+	recorder := recordSpans(t, newSampler(0, nil))
+
+	var handlerBody string
+	router := chi.NewRouter()
+	router.Use(debugSamplingMiddleware([]string{"01310-100"}))
+	router.Use(serverTracing("servico-a"))
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handlerBody = string(body)
+	})
+
+	tests := []struct {
+		name        string
+		body        string
+		wantSampled bool
+	}{
+		{"debug CEP", `{"cep": "01310100"}`, true},
+		{"other CEP", `{"cep": "29902555"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if handlerBody != tt.body {
+				t.Errorf("handler read %q, want the full body %q", handlerBody, tt.body)
+			}
+			sampled := len(recorder.Ended()) > before
+			if sampled != tt.wantSampled {
+				t.Errorf("sampled = %t, want %t with a ratio of 0", sampled, tt.wantSampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// fingerprintBaggageKey carries the request fingerprint to servico-b.
+const fingerprintBaggageKey = "request.fingerprint"
+
+// requestFingerprint derives a stable, content-based key from the normalized
+// CEP and the optional tenant, so identical requests can be correlated
+// across systems independently of trace IDs.
+func requestFingerprint(tenant, cep string) string {
+	sum := sha256.Sum256([]byte(tenant + "\x00" + cep))
+	return hex.EncodeToString(sum[:16])
+}
+
+// withFingerprintBaggage adds fingerprint to the baggage propagated
+// downstream.
+func withFingerprintBaggage(ctx context.Context, fingerprint string) context.Context {
+	member, err := baggage.NewMember(fingerprintBaggageKey, fingerprint)
+	if err != nil {
+		slog.DebugContext(ctx, "Invalid fingerprint baggage member", "error", err)
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to add fingerprint to baggage", "error", err)
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
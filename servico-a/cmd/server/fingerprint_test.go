@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestRequestFingerprintIsStable(t *testing.T) {
+	first := requestFingerprint("acme", "01310100")
+	if again := requestFingerprint("acme", "01310100"); again != first {
+		t.Errorf("fingerprint changed for the same input: %s, then %s", first, again)
+	}
+	if other := requestFingerprint("acme", "29902555"); other == first {
+		t.Errorf("different CEPs share the fingerprint %s", first)
+	}
+	if other := requestFingerprint("globex", "01310100"); other == first {
+		t.Errorf("different tenants share the fingerprint %s", first)
+	}
+}
+
+func TestRequestFingerprintPropagatesToServicoB(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	var received string
+	servicoB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(r.Header))
+		received = baggage.FromContext(ctx).Member(fingerprintBaggageKey).Value()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"city":"São Paulo","temp_C":28.5,"temp_F":83.3,"temp_K":301.7}`))
+	}))
+	t.Cleanup(servicoB.Close)
+
+	var err error
+	servicoBURL, err = parseServicoBURL(servicoB.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servicoBClient = newUpstreamClient(0)
+	fingerprintEnabled = true
+	t.Cleanup(func() { fingerprintEnabled = false })
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"cep": "01310-100"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "acme")
+	rec := httptest.NewRecorder()
+	handleCEP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	want := requestFingerprint("acme", "01310100")
+	if got := rec.Header().Get("X-Request-Fingerprint"); got != want {
+		t.Errorf("X-Request-Fingerprint = %q, want %q", got, want)
+	}
+	if received != want {
+		t.Errorf("servico-b received fingerprint baggage %q, want %q", received, want)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// servicoBTimeout bounds a whole call to servico-b.
+const servicoBTimeout = 10 * time.Second
+
+// servicoBClient is shared by all requests so connections to servico-b are
+// pooled instead of opened per request.
+var servicoBClient = newUpstreamClient(0)
+
+// newUpstreamClient builds the shared client. maxConnsPerHost caps the
+// connections opened to servico-b; zero means no limit. Every call gets an
+// otelhttp client span, whose context is what servico-b sees as its parent.
+func newUpstreamClient(maxConnsPerHost int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = maxConnsPerHost
+	return &http.Client{Transport: otelhttp.NewTransport(transport), Timeout: servicoBTimeout}
+}
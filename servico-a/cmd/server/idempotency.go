@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// storedResponse is a response replayed for a repeated Idempotency-Key.
+type storedResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// replayedHeaders are the stored headers copied into a replay. The others
+// belong to the original exchange: its X-Request-ID, Server-Timing and CORS
+// headers would mislead the new caller.
+var replayedHeaders = []string{"Content-Type", "Retry-After"}
+
+// idempotencyCache keeps responses for ttl, evicting the oldest entries once
+// maxEntries is reached. Entries all live for the same ttl, so insertion
+// order is also expiry order. Keys whose first request is still being
+// handled are held in inflight, so a concurrent duplicate does not reach
+// servico-b as well.
+type idempotencyCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	inflight   map[string]struct{}
+}
+
+func newIdempotencyCache(ttl time.Duration, maxEntries int) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		inflight:   make(map[string]struct{}),
+	}
+}
+
+// reserve returns the stored response for key when there is one. Otherwise
+// it marks key as in flight and reports whether it could: false means
+// another request with the same key is still being handled. A successful
+// reservation must be ended with release.
+func (c *idempotencyCache) reserve(key string) (stored *storedResponse, reserved bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		stored := elem.Value.(*storedResponse)
+		if time.Now().Before(stored.expires) {
+			return stored, false
+		}
+		c.remove(elem)
+	}
+	if _, ok := c.inflight[key]; ok {
+		return nil, false
+	}
+	c.inflight[key] = struct{}{}
+	return nil, true
+}
+
+// release ends the reservation of key, storing the response when it is not
+// nil.
+func (c *idempotencyCache) release(key string, stored *storedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inflight, key)
+	if stored != nil {
+		c.put(stored)
+	}
+}
+
+// put stores a response; c.mu must be held.
+func (c *idempotencyCache) put(stored *storedResponse) {
+	if elem, ok := c.entries[stored.key]; ok {
+		c.remove(elem)
+	}
+	now := time.Now()
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		if c.order.Len() < c.maxEntries && now.Before(front.Value.(*storedResponse).expires) {
+			break
+		}
+		c.remove(front)
+	}
+	stored.expires = now.Add(c.ttl)
+	c.entries[stored.key] = c.order.PushBack(stored)
+}
+
+func (c *idempotencyCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*storedResponse).key)
+}
+
+// idempotencyScope identifies the caller an Idempotency-Key belongs to, so
+// different clients using the same key never get each other's responses.
+// It combines the client IP with a digest of the API key, which keeps the
+// key itself out of the cache.
+func idempotencyScope(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	apiKey := sha256.Sum256([]byte(r.Header.Get(apiKeyHeader)))
+	return ip + "|" + hex.EncodeToString(apiKey[:])
+}
+
+// Middleware replays the stored response for a known Idempotency-Key
+// without calling the handler, and answers 409 while the first request with
+// that key is still being handled. Responses are stored unless they are
+// server errors, which clients are expected to retry.
+func (c *idempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := idempotencyScope(r) + "|" + idempotencyKey
+
+		stored, reserved := c.reserve(key)
+		if stored != nil {
+			ctx := r.Context()
+			_, span := otel.Tracer("servico-a").Start(ctx, "servico-a.idempotentReplay",
+				trace.WithAttributes(requestIDAttribute(r.Context())),
+			)
+			span.AddEvent("idempotency.hit", trace.WithAttributes(
+				attribute.Int("http.status_code", stored.status),
+			))
+			defer span.End()
+
+			for _, name := range replayedHeaders {
+				if value := stored.header.Get(name); value != "" {
+					w.Header().Set(name, value)
+				}
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.status)
+			if _, err := w.Write(stored.body); err != nil {
+				recordWriteFailure(ctx, err)
+			}
+			return
+		}
+		if !reserved {
+			trace.SpanFromContext(r.Context()).AddEvent("idempotency.conflict")
+			writeJSON(r.Context(), w, http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is already in progress"})
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		var response *storedResponse
+		// The reservation is released even if the handler panics, so the
+		// key is not blocked until restart.
+		defer func() { c.release(key, response) }()
+		next.ServeHTTP(recorder, r)
+		if recorder.status != 0 && recorder.status < http.StatusInternalServerError {
+			response = &storedResponse{
+				key:    key,
+				status: recorder.status,
+				header: recorder.header,
+				body:   recorder.body.Bytes(),
+			}
+		}
+	})
+}
+
+// responseRecorder tees a response so it can be stored.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceHandler decorates a slog.Handler, adding the trace and span IDs of the
+// span active in the record's context so log lines can be matched to traces.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+// newLogger creates a JSON logger with trace correlation at the given level
+// (debug, info, warn or error). Unknown levels fall back to info.
+func newLogger(level string) *slog.Logger {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: parseLogLevel(level)})
+	return slog.New(traceHandler{handler})
+}
+
+// parseLogLevel parses debug, info, warn or error, falling back to info.
+func parseLogLevel(level string) slog.Level {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo
+	}
+	return lvl
+}
+
+type accessLogSpanKey struct{}
+
+// accessLog writes one JSON line per request at the given level, replacing
+// chi's middleware.Logger in the same position of the chain. It runs before
+// serverTracing, so the server span is handed back through the request
+// context by rememberServerSpan and traceHandler adds its IDs to the line.
+func accessLog(level slog.Level) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			var spanContext trace.SpanContext
+			r = r.WithContext(context.WithValue(r.Context(), accessLogSpanKey{}, &spanContext))
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			client := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(client); err == nil {
+				client = host
+			}
+			slog.LogAttrs(trace.ContextWithSpanContext(r.Context(), spanContext), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start))/float64(time.Millisecond)),
+				slog.String("client_ip", client),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+		})
+	}
+}
+
+// rememberServerSpan reports the server span to accessLog. It runs inside
+// serverTracing, where the span is in the request context.
+func rememberServerSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if spanContext, ok := r.Context().Value(accessLogSpanKey{}).(*trace.SpanContext); ok {
+			*spanContext = trace.SpanContextFromContext(r.Context())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
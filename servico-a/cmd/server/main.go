@@ -3,140 +3,214 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/credentials"
 )
 
+// defaultMaxBodyBytes caps the request body accepted by handleCEP.
+const defaultMaxBodyBytes = 1 << 20
+
+var maxBodyBytes int64 = defaultMaxBodyBytes
+
+// servicoBURL is the root of servico-b's API, validated at startup.
+var servicoBURL *url.URL
+
+// parseServicoBURL checks that raw is an absolute http(s) URL, so a typo in
+// SERVICO_B_URL fails at startup instead of on every request.
+func parseServicoBURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q must use the http or https scheme", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+	return u, nil
+}
+
+// fingerprintEnabled adds the X-Request-Fingerprint header to POST / responses
+// and propagates the fingerprint to servico-b as baggage.
+var fingerprintEnabled bool
+
 type CEPRequest struct {
-	CEP string `json:"cep"`
+	CEP CEPValue `json:"cep"`
 }
 
 type CEPResponse struct {
-	City   string  `json:"city"`
-	TempC  float64 `json:"temp_C"`
-	TempF  float64 `json:"temp_F"`
-	TempK  float64 `json:"temp_K"`
-}
+	XMLName xml.Name `json:"-" xml:"temperature"`
 
-func initProvider(serviceName, collectorURL string) (func(context.Context) error, error) {
-	ctx := context.Background()
+	City  string  `json:"city" xml:"city"`
+	TempC float64 `json:"temp_C" xml:"temp_C"`
+	TempF float64 `json:"temp_F" xml:"temp_F"`
+	TempK float64 `json:"temp_K" xml:"temp_K"`
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-		),
-	)
+	// Address details, only present for ?verbose=true.
+	Logradouro string `json:"logradouro,omitempty" xml:"logradouro,omitempty"`
+	Bairro     string `json:"bairro,omitempty" xml:"bairro,omitempty"`
+	UF         string `json:"uf,omitempty" xml:"uf,omitempty"`
+
+	// Providers is passed through from servico-b when it runs with
+	// INCLUDE_PROVIDERS=true. It is JSON, so it is left out of XML responses.
+	Providers json.RawMessage `json:"providers,omitempty" xml:"-"`
+}
+
+func initProvider(ctx context.Context, cfg Config, creds credentials.TransportCredentials) (func(context.Context) error, error) {
+	res, err := newResource(ctx, cfg.ServiceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Retry logic para conectar ao collector
-	var conn *grpc.ClientConn
-	maxRetries := 20
-	retryDelay := 2 * time.Second
-	
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		var err error
-		conn, err = grpc.DialContext(ctx, collectorURL,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithBlock(),
-		)
-		cancel()
-		
-		if err == nil {
-			log.Printf("Successfully connected to OTEL collector after %d attempts", i+1)
-			break
-		}
-		
-		if i < maxRetries-1 {
-			log.Printf("Failed to connect to collector (attempt %d/%d): %v. Retrying in %v...", i+1, maxRetries, err, retryDelay)
-			time.Sleep(retryDelay)
-		} else {
-			return nil, fmt.Errorf("failed to create gRPC connection to collector after %d attempts: %w", maxRetries, err)
-		}
+	// The Prometheus reader does not depend on the collector, so /metrics
+	// keeps serving the app metrics when the OTLP pipeline is down or
+	// disabled, which is when scraping them directly matters most.
+	promExporter, err := prometheus.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+	// Histogram observations made under a sampled span keep its trace ID as
+	// an exemplar, linking a latency bucket to an example trace.
+	// OTEL_METRICS_EXEMPLAR_FILTER overrides the filter.
+	meterOptions := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+		sdkmetric.WithReader(promExporter),
+	}
+
+	// OTEL_SDK_DISABLED skips the collector entirely; handlers keep creating
+	// spans, which the noop provider discards.
+	if cfg.SDKDisabled {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		meterProvider := sdkmetric.NewMeterProvider(meterOptions...)
+		otel.SetMeterProvider(meterProvider)
+		slog.Info("OpenTelemetry SDK disabled")
+		return meterProvider.Shutdown, nil
 	}
 
-	traceExporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithGRPCConn(conn))
+	conn, err := dialCollector(ctx, cfg.CollectorEndpoints, creds, cfg.DialBlocking)
+	if err != nil {
+		otel.SetMeterProvider(sdkmetric.NewMeterProvider(meterOptions...))
+		return nil, err
+	}
+
+	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
+	bsp := sdktrace.NewBatchSpanProcessor(traceExporter,
+		sdktrace.WithMaxQueueSize(cfg.BSPMaxQueueSize),
+		sdktrace.WithMaxExportBatchSize(cfg.BSPMaxExportBatchSize),
+		sdktrace.WithExportTimeout(cfg.BSPExportTimeout),
+		sdktrace.WithBatchTimeout(cfg.BSPScheduleDelay),
+	)
+	slog.Info("Batch span processor configured",
+		"max_queue_size", cfg.BSPMaxQueueSize,
+		"max_export_batch_size", cfg.BSPMaxExportBatchSize,
+		"export_timeout", cfg.BSPExportTimeout.String(),
+		"schedule_delay", cfg.BSPScheduleDelay.String(),
+	)
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(newSampler(cfg.TraceSampleRatio, cfg.TraceIgnoreRoutes)),
 		sdktrace.WithResource(res),
 		sdktrace.WithSpanProcessor(bsp),
 	)
 	otel.SetTracerProvider(tracerProvider)
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-
-	return tracerProvider.Shutdown, nil
-}
-
-func validateCEP(cep string) bool {
-	if len(cep) != 8 {
-		return false
+	propagator := newPropagator(cfg.Propagators)
+	if cfg.TraceContextHeader != "" {
+		propagator = customHeaderPropagator{header: cfg.TraceContextHeader, fallback: propagator}
 	}
-	for _, char := range cep {
-		if char < '0' || char > '9' {
-			return false
-		}
+	otel.SetTextMapPropagator(propagator)
+
+	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
 	}
-	return true
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		append(meterOptions, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))...,
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tracerProvider.Shutdown(ctx), meterProvider.Shutdown(ctx))
+	}, nil
 }
 
 func handleCEP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tracer := otel.Tracer("servico-a")
-	
-	ctx, span := tracer.Start(ctx, "servico-a.handleCEP")
+
+	ctx, span := tracer.Start(ctx, "servico-a.handleCEP",
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
 	defer span.End()
 
-	var req CEPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	req, err := decodeCEPRequest(ctx, w, r)
+	if err != nil {
 		span.RecordError(err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeNegotiated(ctx, w, r, http.StatusBadRequest, ErrorResponse{Error: decodeErrorMessage(err)})
 		return
 	}
 
+	cep := normalizeCEP(req.CEP.Value)
+	if req.CEP.Padded {
+		span.AddEvent("cep.normalized", trace.WithAttributes(attribute.String("cep", cep)))
+	}
+
 	ctx, validateSpan := tracer.Start(ctx, "servico-a.validateCEP")
-	isValid := validateCEP(req.CEP)
+	err = validateCEP(cep)
+	if errors.Is(err, errCEPRange) {
+		validateSpan.AddEvent("cep.prefilter.rejected", trace.WithAttributes(attribute.String("cep", cep)))
+	}
 	validateSpan.End()
 
-	if !isValid {
-		span.RecordError(fmt.Errorf("invalid zipcode"))
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid zipcode"})
+	if err != nil {
+		span.RecordError(err)
+		writeNegotiated(ctx, w, r, http.StatusUnprocessableEntity, ErrorResponse{Error: "invalid zipcode"})
 		return
 	}
 
-	servicoBURL := os.Getenv("SERVICO_B_URL")
-	if servicoBURL == "" {
-		servicoBURL = "http://servico-b:8081"
+	if fingerprintEnabled {
+		fingerprint := requestFingerprint(r.Header.Get("X-Tenant-ID"), cep)
+		w.Header().Set("X-Request-Fingerprint", fingerprint)
+		span.SetAttributes(attribute.String("request.fingerprint", fingerprint))
+		ctx = withFingerprintBaggage(ctx, fingerprint)
 	}
 
 	ctx, callSpan := tracer.Start(ctx, "servico-a.callServicoB")
 	defer callSpan.End()
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", servicoBURL+"/temperature", nil)
+	targetURL := servicoBURL.JoinPath("temperature")
+	targetURL.RawQuery = forwardedOptions(r).Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", targetURL.String(), nil)
 	if err != nil {
 		callSpan.RecordError(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -144,12 +218,33 @@ func handleCEP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-CEP", req.CEP)
-	
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	httpReq.Header.Set("X-CEP", cep)
+	relayServicoB(ctx, w, r, httpReq)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(httpReq)
+// forwardedOptions returns the verbose, lang, aqi and precision query
+// parameters of r.
+// They are options of servico-b's lookup and are forwarded as given;
+// servico-b validates them.
+func forwardedOptions(r *http.Request) url.Values {
+	forwarded := url.Values{}
+	for _, name := range []string{"verbose", "lang", "aqi", "precision"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			forwarded.Set(name, value)
+		}
+	}
+	return forwarded
+}
+
+// relayServicoB sends httpReq to servico-b and writes its answer to w: a
+// CEPResponse on success, servico-b's error body otherwise. Failures are
+// recorded on the span in ctx.
+func relayServicoB(ctx context.Context, w http.ResponseWriter, r *http.Request, httpReq *http.Request) {
+	callSpan := trace.SpanFromContext(ctx)
+	httpReq.Header.Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+
+	startTime := time.Now()
+	resp, err := servicoBClient.Do(httpReq)
 	if err != nil {
 		callSpan.RecordError(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -157,7 +252,10 @@ func handleCEP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, err := readUpstreamBody(ctx, resp.Body)
+	// The round trip includes reading the body, so it is comparable with the
+	// timings servico-b reports for its own upstreams.
+	w.Header().Set("Server-Timing", fmt.Sprintf("servico-b;dur=%.1f", float64(time.Since(startTime))/float64(time.Millisecond)))
 	if err != nil {
 		callSpan.RecordError(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -166,9 +264,22 @@ func handleCEP(w http.ResponseWriter, r *http.Request) {
 
 	// Se não for status 200, retornar o erro do servico-b
 	if resp.StatusCode != http.StatusOK {
+		recordUpstreamError(ctx, resp.StatusCode, bodyBytes)
+		// servico-b passes on the weather provider's Retry-After when it
+		// is throttled.
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		if prefersXML(r.Header.Get("Accept")) {
+			writeNegotiated(ctx, w, r, resp.StatusCode, servicoBError(bodyBytes))
+			return
+		}
+		w.Header().Add("Vary", "Accept")
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(resp.StatusCode)
-		w.Write(bodyBytes)
+		if _, err := w.Write(bodyBytes); err != nil {
+			recordWriteFailure(ctx, err)
+		}
 		return
 	}
 
@@ -179,63 +290,229 @@ func handleCEP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(cepResp)
+	writeNegotiated(ctx, w, r, http.StatusOK, cepResp)
+}
+
+// decodeCEPRequest reads the JSON body shared by POST / and
+// POST /temperature/validate in a "decode.request" span.
+func decodeCEPRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (CEPRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	body := &countingReader{r: r.Body}
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	var req CEPRequest
+	_, decodeSpan := otel.Tracer("servico-a").Start(ctx, "decode.request")
+	err := decoder.Decode(&req)
+	decodeSpan.SetAttributes(attribute.Int64("decode.size_bytes", body.n))
+	decodeSpan.End()
+	return req, err
+}
+
+// decodeErrorMessage describes a decodeCEPRequest failure for the client.
+func decodeErrorMessage(err error) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, io.EOF) {
+		return "request body is empty"
+	} else if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit)
+	}
+	return fmt.Sprintf("invalid request body: %v", err)
+}
+
+// CEPValidationResponse is the body of GET /cep/{cep}/validate and POST
+// /temperature/validate. Reason is null when the CEP is valid.
+type CEPValidationResponse struct {
+	Valid      bool    `json:"valid"`
+	Normalized string  `json:"normalized"`
+	Reason     *string `json:"reason"`
+}
+
+// handleValidateOnly runs the normalization and validation of POST / on the
+// same request body, without calling servico-b, so clients can catch typos
+// before spending a lookup.
+func handleValidateOnly(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("servico-a").Start(r.Context(), "servico-a.validateOnly",
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
+	defer span.End()
+
+	req, err := decodeCEPRequest(ctx, w, r)
+	if err != nil {
+		span.RecordError(err)
+		writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": decodeErrorMessage(err)})
+		return
+	}
+
+	cep := normalizeCEP(req.CEP.Value)
+	span.SetAttributes(attribute.String("cep", cep))
+	if err := validateCEP(cep); err != nil {
+		span.RecordError(err)
+		reason := err.Error()
+		writeJSON(ctx, w, http.StatusUnprocessableEntity, CEPValidationResponse{Normalized: cep, Reason: &reason})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, CEPValidationResponse{Valid: true, Normalized: cep})
+}
+
+// handleValidateCEP reports whether a CEP is well formed without looking it
+// up, so frontends can validate user input inline.
+func handleValidateCEP(w http.ResponseWriter, r *http.Request) {
+	tracer := otel.Tracer("servico-a")
+	ctx, span := tracer.Start(r.Context(), "servico-a.handleValidateCEP",
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
+	defer span.End()
+
+	cep := normalizeCEP(chi.URLParam(r, "cep"))
+	response := CEPValidationResponse{Valid: true, Normalized: cep}
+	if err := validateCEP(cep); err != nil {
+		reason := err.Error()
+		response.Valid = false
+		response.Reason = &reason
+	}
+
+	writeJSON(ctx, w, http.StatusOK, response)
 }
 
 func main() {
+	slog.SetDefault(newLogger(os.Getenv("LOG_LEVEL")))
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	collectorURL := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if collectorURL == "" {
-		collectorURL = "otel-collector:4317"
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	servicoBURL, err = parseServicoBURL(cfg.ServicoBURL)
+	if err != nil {
+		slog.Error("Invalid SERVICO_B_URL", "error", err)
+		os.Exit(1)
+	}
+	maxBodyBytes = cfg.MaxBodyBytes
+	upstreamErrorSnippet = cfg.UpstreamErrorSnippet
+	maxUpstreamResponseBytes = cfg.UpstreamMaxResponseBytes
+	fingerprintEnabled = os.Getenv("REQUEST_FINGERPRINT_ENABLED") == "true"
+	forceTraceEnabled = os.Getenv("DEBUG_FORCE_TRACE") == "true"
+	servicoBClient = newUpstreamClient(int(cfg.UpstreamMaxConnsPerHost))
+
+	trustedProxies, err := parseTrustedProxies(getEnvList("TRUSTED_PROXIES", nil))
+	if err != nil {
+		slog.Error("Invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
 	}
 
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "servico-a"
+	endpoints, scheme, err := parseCollectorEndpoints(cfg.CollectorEndpoints)
+	if err != nil {
+		slog.Error("Invalid OTEL_EXPORTER_OTLP_ENDPOINT", "error", err)
+		os.Exit(1)
 	}
+	cfg.CollectorEndpoints = endpoints
 
-	shutdown, err := initProvider(serviceName, collectorURL)
+	creds, err := collectorCredentials(scheme)
 	if err != nil {
-		log.Printf("Warning: Failed to initialize OTEL provider: %v. Continuing without tracing.", err)
+		slog.Error("Invalid OTEL collector TLS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	shutdown, err := initProvider(ctx, cfg, creds)
+	if errors.Is(err, context.Canceled) {
+		slog.Info("Startup interrupted while connecting to the OTEL collector")
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to initialize OTEL provider, continuing without tracing", "error", err)
 		shutdown = func(context.Context) error { return nil }
 	}
+	if os.Getenv("WARMUP_TRACES") == "true" {
+		go warmupTraces(ctx)
+	}
+
 	defer func() {
-		if err := shutdown(ctx); err != nil {
-			log.Printf("Warning: Failed to shutdown TracerProvider: %v", err)
+		// ctx is already cancelled once a signal arrives, so flushing the
+		// buffered spans gets its own deadline.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.TraceShutdownTimeout)
+		defer cancel()
+		if err := shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shutdown telemetry providers", "error", err)
 		}
 	}()
 
+	if err := initMetrics(); err != nil {
+		slog.Error("Failed to initialize metrics", "error", err)
+		os.Exit(1)
+	}
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
-	router.Use(middleware.Logger)
+	router.Use(echoRequestID)
+	if len(trustedProxies) > 0 {
+		router.Use(trustedClientIP(trustedProxies))
+	} else {
+		router.Use(middleware.RealIP)
+	}
+	router.Use(accessLog(parseLogLevel(getEnv("ACCESS_LOG_LEVEL", "info"))))
 	router.Use(middleware.Recoverer)
-	router.Post("/", handleCEP)
-
-	port := os.Getenv("HTTP_PORT")
-	if port == "" {
-		port = ":8080"
+	router.Use(compressResponses(cfg.CompressMinSize))
+	router.Use(debugSamplingMiddleware(getEnvList("TRACE_DEBUG_CEPS", nil)))
+	router.Use(forceTraceMiddleware)
+	router.Use(serverTracing(cfg.ServiceName))
+	router.Use(metricsMiddleware)
+	router.Use(cors.Handler(cors.Options{
+		AllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowedHeaders: []string{"Accept", "Content-Type", apiKeyHeader},
+		MaxAge:         300,
+	}))
+	if os.Getenv("RATE_LIMIT_DISABLED") != "true" {
+		limiter, err := newIPRateLimiter(getEnvFloat("RATE_LIMIT_RPS", 10), int(getEnvInt64("RATE_LIMIT_BURST", 20)))
+		if err != nil {
+			slog.Error("Invalid RATE_LIMIT_RPS or RATE_LIMIT_BURST", "error", err)
+			os.Exit(1)
+		}
+		router.Use(limiter.Middleware)
 	}
+	router.Handle("/metrics", metricsHandler())
+	router.Get("/version", handleVersion)
+	if os.Getenv("DEBUG_ENDPOINTS") == "true" {
+		router.Get("/debug/config", debugConfigHandler(cfg, creds))
+	}
+	idempotency := newIdempotencyCache(
+		getEnvDuration("IDEMPOTENCY_TTL", 5*time.Minute),
+		int(getEnvInt64("IDEMPOTENCY_MAX_ENTRIES", 1000)),
+	)
+	bulkhead := newBulkhead(int(getEnvInt64("MAX_INFLIGHT", 100)))
+	router.Group(func(api chi.Router) {
+		// /metrics and /version stay open so scrapes and health checks
+		// work without the key.
+		api.Use(requireAPIKey(os.Getenv("API_KEY")))
+		api.With(requireJSON, bulkhead.Middleware, idempotency.Middleware).Post("/", handleCEP)
+		api.Get("/cep/{cep}/validate", handleValidateCEP)
+		api.With(requireJSON).Post("/temperature/validate", handleValidateOnly)
+		api.With(requireJSON, bulkhead.Middleware).Post("/temperature/city", handleCity)
+	})
+	router.NotFound(handleNotFound)
+	router.MethodNotAllowed(handleMethodNotAllowed)
 
 	go func() {
-		log.Printf("Serviço A iniciado na porta %s", port)
-		if err := http.ListenAndServe(port, router); err != nil {
-			log.Fatal(err)
+		slog.Info("Serviço A iniciado", "addr", cfg.HTTPAddr)
+		if err := http.ListenAndServe(cfg.HTTPAddr, router); err != nil {
+			slog.Error("HTTP server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	select {
 	case <-sigCh:
-		log.Println("Shutting down gracefully, CTRL+C pressed...")
+		slog.Info("Shutting down gracefully, CTRL+C pressed...")
 	case <-ctx.Done():
-		log.Println("Shutting down due to other reason...")
+		slog.Info("Shutting down due to other reason...")
 	}
 }
-
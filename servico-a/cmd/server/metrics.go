@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+var (
+	requestCount    metric.Int64Counter
+	requestDuration metric.Float64Histogram
+)
+
+// initMetrics creates the instruments on the global MeterProvider.
+func initMetrics() error {
+	meter := otel.Meter("servico-a")
+
+	var err error
+	requestCount, err = meter.Int64Counter("http.server.request.count",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of HTTP requests handled"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request counter: %w", err)
+	}
+
+	requestDuration, err = meter.Float64Histogram("http.server.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Duration of HTTP requests"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request duration histogram: %w", err)
+	}
+	return nil
+}
+
+// metricsHandler serves /metrics. Exemplars are only part of the OpenMetrics
+// format, which is served when the scraper asks for it.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
+}
+
+// metricsMiddleware counts requests and records their latency labeled by
+// route, method and status code. It runs inside serverTracing, so the
+// request context carries the server span and the latency observation
+// gets its trace ID as an exemplar.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := metric.WithAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPStatusCode(status),
+		)
+		requestCount.Add(r.Context(), 1, attrs)
+		requestDuration.Record(r.Context(), float64(time.Since(startTime))/float64(time.Millisecond), attrs)
+	})
+}
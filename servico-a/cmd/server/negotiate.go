@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrorResponse is the error body of POST /. Its JSON form is the same
+// {"error": "..."} object written by the other handlers.
+type ErrorResponse struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Error   string   `json:"error" xml:"message"`
+}
+
+// prefersXML reports whether the Accept header ranks application/xml (or
+// text/xml) above application/json. Wildcards, a missing header and ties
+// resolve to JSON.
+func prefersXML(accept string) bool {
+	bestXML, bestJSON := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "application/xml", "text/xml":
+			bestXML = max(bestXML, q)
+		case "application/json", "*/*", "application/*":
+			bestJSON = max(bestJSON, q)
+		}
+	}
+	return bestXML > 0 && bestXML > bestJSON
+}
+
+// writeNegotiated writes v as XML when the request prefers it and as JSON
+// otherwise.
+func writeNegotiated(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	if prefersXML(r.Header.Get("Accept")) {
+		writeXML(ctx, w, status, v)
+		return
+	}
+	writeJSON(ctx, w, status, v)
+}
+
+// writeXML is the XML counterpart of writeJSON.
+func writeXML(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+
+	_, span := otel.Tracer("servico-a").Start(ctx, "encode.response")
+	counter := &countingWriter{w: w}
+	_, err := counter.Write([]byte(xml.Header))
+	if err == nil {
+		err = xml.NewEncoder(counter).Encode(v)
+	}
+	span.SetAttributes(
+		attribute.Int64("encode.size_bytes", counter.n),
+		attribute.String("encode.format", "xml"),
+	)
+	span.End()
+	if err != nil {
+		recordWriteFailure(ctx, err)
+	}
+}
+
+// servicoBError extracts the message of an error body relayed from
+// servico-b, falling back to the raw body.
+func servicoBError(body []byte) ErrorResponse {
+	var resp ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == "" {
+		return ErrorResponse{Error: strings.TrimSpace(string(body))}
+	}
+	return resp
+}
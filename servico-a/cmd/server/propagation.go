@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// customHeaderPropagator extracts a W3C traceparent value sent under a
+// non-standard header name, falling back to the wrapped propagator when the
+// header is absent or malformed. Injection is always delegated, so requests
+// to servico-b keep using the standard headers.
+type customHeaderPropagator struct {
+	header   string
+	fallback propagation.TextMapPropagator
+}
+
+func (p customHeaderPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if value := carrier.Get(p.header); value != "" {
+		extracted := propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": value})
+		if trace.SpanContextFromContext(extracted).IsValid() {
+			return extracted
+		}
+	}
+	return p.fallback.Extract(ctx, carrier)
+}
+
+func (p customHeaderPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	p.fallback.Inject(ctx, carrier)
+}
+
+func (p customHeaderPropagator) Fields() []string {
+	return append(p.fallback.Fields(), p.header)
+}
+
+// propagators maps the names accepted in OTEL_PROPAGATORS to their
+// implementations. "b3" injects the single b3 header and "b3multi" the
+// X-B3-* headers; both extract either form.
+var propagators = map[string]propagation.TextMapPropagator{
+	"tracecontext": propagation.TraceContext{},
+	"baggage":      propagation.Baggage{},
+	"b3":           b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)),
+	"b3multi":      b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
+}
+
+// newPropagator combines the named propagators, in order. Names are checked
+// by loadConfig.
+func newPropagator(names []string) propagation.TextMapPropagator {
+	selected := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		selected = append(selected, propagators[name])
+	}
+	return propagation.NewCompositeTextMapPropagator(selected...)
+}
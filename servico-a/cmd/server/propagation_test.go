@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCustomHeaderPropagator(t *testing.T) {
+	const (
+		partnerTraceparent  = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		standardTraceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	)
+	p := customHeaderPropagator{header: "X-Partner-Trace", fallback: propagation.TraceContext{}}
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		wantTraceID string
+	}{
+		{
+			"custom header",
+			map[string]string{"x-partner-trace": partnerTraceparent},
+			"4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			"custom header wins over traceparent",
+			map[string]string{"x-partner-trace": partnerTraceparent, "traceparent": standardTraceparent},
+			"4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			"malformed custom header falls back",
+			map[string]string{"x-partner-trace": "garbage", "traceparent": standardTraceparent},
+			"0af7651916cd43dd8448eb211c80319c",
+		},
+		{
+			"no custom header falls back",
+			map[string]string{"traceparent": standardTraceparent},
+			"0af7651916cd43dd8448eb211c80319c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := propagation.HeaderCarrier{}
+			for name, value := range tt.headers {
+				carrier.Set(name, value)
+			}
+			sc := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+			if !sc.IsRemote() {
+				t.Errorf("extracted span context is not remote")
+			}
+			if got := sc.TraceID().String(); got != tt.wantTraceID {
+				t.Errorf("trace ID = %s, want %s", got, tt.wantTraceID)
+			}
+		})
+	}
+}
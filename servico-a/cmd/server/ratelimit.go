@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// clientIdleTTL is how long a client's bucket is kept after its last request.
+const clientIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter keeps one token bucket per client IP.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	rps       rate.Limit
+	burst     int
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+// newIPRateLimiter rejects a non-positive rps or burst: such a bucket never
+// refills or never holds a token, so every request would wait forever.
+func newIPRateLimiter(rps float64, burst int) (*ipRateLimiter, error) {
+	if rps <= 0 {
+		return nil, fmt.Errorf("rate must be positive, got %v", rps)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("burst must be positive, got %d", burst)
+	}
+	return &ipRateLimiter{
+		rps:       rate.Limit(rps),
+		burst:     burst,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}, nil
+}
+
+func (l *ipRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > clientIdleTTL {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) > clientIdleTTL {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+	return client.limiter
+}
+
+// Middleware rejects requests over the client's rate with 429 and a
+// Retry-After header. It must run after middleware.RealIP so RemoteAddr
+// holds the client address.
+func (l *ipRateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+
+		reservation := l.get(ip).Reserve()
+		delay := reservation.Delay()
+		if delay == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reservation.Cancel()
+
+		tracer := otel.Tracer("servico-a")
+		ctx, span := tracer.Start(r.Context(), "servico-a.rateLimited")
+		defer span.End()
+		span.AddEvent("ratelimit.rejected", trace.WithAttributes(
+			attribute.String("client.ip", ip),
+			attribute.Float64("ratelimit.retry_after_s", delay.Seconds()),
+		))
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		writeJSON(ctx, w, http.StatusTooManyRequests, map[string]string{"error": "too many requests"})
+	})
+}
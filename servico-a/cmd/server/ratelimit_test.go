@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestNewIPRateLimiterRejectsNonPositiveValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		rps     float64
+		burst   int
+		wantErr bool
+	}{
+		{"defaults", 10, 20, false},
+		{"zero rate", 0, 20, true},
+		{"negative rate", -1, 20, true},
+		{"zero burst", 10, 0, true},
+		{"negative burst", 10, -5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newIPRateLimiter(tt.rps, tt.burst)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newIPRateLimiter(%v, %d) error = %v, want error %t", tt.rps, tt.burst, err, tt.wantErr)
+			}
+		})
+	}
+}
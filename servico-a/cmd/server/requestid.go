@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// echoRequestID returns the request ID assigned by middleware.RequestID to
+// the client in the X-Request-ID header. It must run after RequestID.
+func echoRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDAttribute records the request ID on a span so traces can be
+// matched with the access logs.
+func requestIDAttribute(ctx context.Context) attribute.KeyValue {
+	return attribute.String("http.request_id", middleware.GetReqID(ctx))
+}
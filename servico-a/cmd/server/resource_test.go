@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestNewResourceCloudRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		region     string
+		wantRegion bool
+	}{
+		{"set", "sa-east-1", true},
+		{"unset", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CLOUD_REGION", tt.region)
+
+			res, err := newResource(context.Background(), "servico-a")
+			if err != nil {
+				t.Fatalf("newResource: %v", err)
+			}
+			got, ok := res.Set().Value(semconv.CloudRegionKey)
+			if ok != tt.wantRegion {
+				t.Fatalf("cloud.region present = %v, want %v", ok, tt.wantRegion)
+			}
+			if ok && got.AsString() != tt.region {
+				t.Errorf("cloud.region = %q, want %q", got.AsString(), tt.region)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// brokenWriter fails every body write, as when the client has disconnected.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func TestWriteJSONRecordsWriteFailure(t *testing.T) {
+	recorder := recordSpans(t, sdktrace.AlwaysSample())
+
+	ctx, span := otel.Tracer("servico-a").Start(context.Background(), "handler")
+	writeJSON(ctx, brokenWriter{httptest.NewRecorder()}, http.StatusOK, map[string]string{"city": "São Paulo"})
+	span.End()
+
+	spans := recorder.Ended()
+	handler := spans[len(spans)-1]
+	if handler.Name() != "handler" {
+		t.Fatalf("last ended span = %q, want handler", handler.Name())
+	}
+	for _, event := range handler.Events() {
+		if event.Name == "response.write_failed" {
+			return
+		}
+	}
+	t.Errorf("span events = %v, want response.write_failed", handler.Events())
+}
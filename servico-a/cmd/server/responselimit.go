@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultMaxUpstreamResponseBytes caps the body read from an upstream
+// response.
+const defaultMaxUpstreamResponseBytes = 1 << 20
+
+var maxUpstreamResponseBytes int64 = defaultMaxUpstreamResponseBytes
+
+// errResponseTooLarge is returned by readUpstreamBody for bodies over the
+// limit.
+type errResponseTooLarge struct {
+	Limit int64
+}
+
+func (e *errResponseTooLarge) Error() string {
+	return fmt.Sprintf("upstream response body exceeds %d bytes", e.Limit)
+}
+
+// readUpstreamBody reads body up to maxUpstreamResponseBytes, so a
+// misbehaving upstream cannot exhaust memory. Past the limit it returns the
+// bytes read so far with an *errResponseTooLarge and adds a
+// "response.truncated" event to the span in ctx.
+func readUpstreamBody(ctx context.Context, body io.Reader) ([]byte, error) {
+	limit := maxUpstreamResponseBytes
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return data, err
+	}
+	if int64(len(data)) > limit {
+		trace.SpanFromContext(ctx).AddEvent("response.truncated", trace.WithAttributes(
+			attribute.Int64("response.max_bytes", limit),
+		))
+		return data[:limit], &errResponseTooLarge{Limit: limit}
+	}
+	return data, nil
+}
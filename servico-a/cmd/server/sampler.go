@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSampler keeps the historical AlwaysSample behavior for a ratio of 1 and
+// uses a parent-based ratio sampler below that. Either way the decision is
+// recorded on the service's entry spans. Requests marked by
+// debugSamplingMiddleware or forceTraceMiddleware are always sampled.
+func newSampler(ratio float64, ignoreRoutes []string) sdktrace.Sampler {
+	var base sdktrace.Sampler = sdktrace.AlwaysSample()
+	if ratio < 1 {
+		base = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	}
+	if len(ignoreRoutes) > 0 {
+		base = newRouteIgnoringSampler(base, ignoreRoutes)
+	}
+	return decisionRecordingSampler{base: base, ratio: ratio}
+}
+
+// routeIgnoringSampler drops entry spans whose name, or the path in a
+// "<METHOD> <path>" server span name, is in the suppression list, together
+// with their descendants. Everything else is delegated to base.
+type routeIgnoringSampler struct {
+	base   sdktrace.Sampler
+	routes map[string]bool
+}
+
+func newRouteIgnoringSampler(base sdktrace.Sampler, routes []string) routeIgnoringSampler {
+	set := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		set[route] = true
+	}
+	return routeIgnoringSampler{base: base, routes: set}
+}
+
+func (s routeIgnoringSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+	if parent.IsValid() && !parent.IsRemote() {
+		if !parent.IsSampled() {
+			return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: parent.TraceState()}
+		}
+		return s.base.ShouldSample(p)
+	}
+
+	_, path, _ := strings.Cut(p.Name, " ")
+	if s.routes[p.Name] || s.routes[path] {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: parent.TraceState()}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s routeIgnoringSampler) Description() string {
+	return fmt.Sprintf("RouteIgnoring{%s}", s.base.Description())
+}
+
+// decisionRecordingSampler delegates to base and adds the sampling decision
+// and the configured ratio as attributes of spans that start a trace or
+// continue a remote one, so sampled traces show how the sampler behaved.
+type decisionRecordingSampler struct {
+	base  sdktrace.Sampler
+	ratio float64
+}
+
+func (s decisionRecordingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+
+	var result sdktrace.SamplingResult
+	if forceSampled(p.ParentContext) {
+		result = sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: parent.TraceState(),
+			Attributes: []attribute.KeyValue{attribute.Bool("sampling.forced", true)},
+		}
+	} else {
+		result = s.base.ShouldSample(p)
+	}
+
+	if !parent.IsValid() || parent.IsRemote() {
+		result.Attributes = append(result.Attributes,
+			attribute.String("sampling.decision", samplingDecisionName(result.Decision)),
+			attribute.Float64("sampling.ratio", s.ratio),
+		)
+	}
+	return result
+}
+
+func (s decisionRecordingSampler) Description() string {
+	return fmt.Sprintf("DecisionRecording{%s}", s.base.Description())
+}
+
+func samplingDecisionName(decision sdktrace.SamplingDecision) string {
+	switch decision {
+	case sdktrace.RecordAndSample:
+		return "record_and_sample"
+	case sdktrace.RecordOnly:
+		return "record_only"
+	default:
+		return "drop"
+	}
+}
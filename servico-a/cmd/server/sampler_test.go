@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestSampledServerSpanRecordsDecision(t *testing.T) {
+	recorder := recordSpans(t, newSampler(1, nil))
+
+	router := chi.NewRouter()
+	router.Use(serverTracing("servico-a"))
+	router.Get("/cep/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := otel.Tracer("test").Start(r.Context(), "child")
+		span.End()
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cep/01310100", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	for _, span := range spans {
+		attrs := attribute.NewSet(span.Attributes()...)
+		decision, hasDecision := attrs.Value("sampling.decision")
+		ratio, hasRatio := attrs.Value("sampling.ratio")
+		if span.Name() == "child" {
+			if hasDecision || hasRatio {
+				t.Errorf("child span carries the sampling attributes: %v", span.Attributes())
+			}
+			continue
+		}
+		if decision.AsString() != "record_and_sample" {
+			t.Errorf("server span sampling.decision = %q, want %q", decision.Emit(), "record_and_sample")
+		}
+		if ratio.AsFloat64() != 1 {
+			t.Errorf("server span sampling.ratio = %s, want 1", ratio.Emit())
+		}
+	}
+}
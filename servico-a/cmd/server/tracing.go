@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths are the operational endpoints that get no server span:
+// Prometheus scrapes, deploy checks of /version and /debug/config.
+var untracedPaths = map[string]bool{
+	"/metrics":      true,
+	"/version":      true,
+	"/debug/config": true,
+}
+
+// serverTracing starts an otelhttp server span for every request, continuing
+// the trace extracted from the incoming headers. The untracedPaths are left
+// out. Handlers start their business spans as children of this one.
+func serverTracing(serviceName string) func(http.Handler) http.Handler {
+	instrument := otelhttp.NewMiddleware(serviceName,
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+		// The sampler only sees the name the span starts with, which has
+		// to carry the path for TRACE_IGNORE_ROUTES to match.
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+	return func(next http.Handler) http.Handler {
+		return instrument(rememberServerSpan(recordPanic(recordPayloadSizes(annotateServerSpan(next)))))
+	}
+}
+
+// recordPanic marks the server span as failed when a handler panics, then
+// re-panics so middleware.Recoverer still logs the panic and answers 500.
+// It runs inside serverTracing, while the span is still open.
+func recordPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec != http.ErrAbortHandler {
+				err, ok := rec.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", rec)
+				}
+				span := trace.SpanFromContext(r.Context())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, err.Error())
+				span.SetAttributes(semconv.HTTPStatusCode(http.StatusInternalServerError))
+			}
+			panic(rec)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordPayloadSizes records the bytes actually read from the request body
+// and written to the response body on the server span; both are 0 when
+// there is no body.
+func recordPayloadSizes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &countingReader{r: r.Body}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, r.Body}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.Int64("http.request_content_length", body.n),
+			attribute.Int("http.response_content_length", ww.BytesWritten()),
+		)
+	})
+}
+
+// annotateServerSpan renames the server span after the chi route pattern
+// once routing is done, so span names stay low-cardinality
+// ("GET /cep/{cep}/validate"), and records the resolved client address.
+func annotateServerSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		client := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+		span.SetAttributes(attribute.String("client.ip", client))
+
+		next.ServeHTTP(w, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			return
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	})
+}
+
+// warmupTraces exports a throwaway span right after startup so the exporter's
+// gRPC stream to the collector is already open when real traffic arrives.
+func warmupTraces(ctx context.Context) {
+	_, span := otel.Tracer("servico-a").Start(ctx, "servico-a.warmup",
+		trace.WithAttributes(attribute.Bool("warmup", true)),
+	)
+	span.End()
+
+	flusher, ok := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error })
+	if !ok {
+		return
+	}
+	if err := flusher.ForceFlush(ctx); err != nil {
+		slog.Warn("Failed to flush warmup span", "error", err)
+		return
+	}
+	slog.Debug("Trace exporter warmed up")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultUpstreamErrorSnippet is the default number of bytes of a servico-b
+// error body kept on the "upstream.error" event.
+const defaultUpstreamErrorSnippet = 256
+
+var upstreamErrorSnippet = defaultUpstreamErrorSnippet
+
+// secretParams matches credentials that show up in error messages, most
+// often the query string of a failed upstream URL ("?key=...").
+var secretParams = regexp.MustCompile(`(?i)\b(key|appid|api_key|apikey|token|access_token|password|secret)=[^&\s"']*`)
+
+// errorSnippet redacts credentials from body and truncates it to at most
+// limit bytes, without splitting a UTF-8 character.
+func errorSnippet(body []byte, limit int) string {
+	snippet := secretParams.ReplaceAllString(strings.TrimSpace(string(body)), "$1=REDACTED")
+	if len(snippet) <= limit {
+		return snippet
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+		cut--
+	}
+	return snippet[:cut] + "..."
+}
+
+// recordUpstreamError marks the span in ctx as failed because servico-b
+// answered with a non-200 status, so the failure is visible from servico-a's
+// side of the trace.
+func recordUpstreamError(ctx context.Context, status int, body []byte) {
+	span := trace.SpanFromContext(ctx)
+	attrs := []attribute.KeyValue{attribute.Int("upstream.status_code", status)}
+	if upstreamErrorSnippet > 0 {
+		attrs = append(attrs, attribute.String("upstream.body", errorSnippet(body, upstreamErrorSnippet)))
+	}
+	span.AddEvent("upstream.error", trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, fmt.Sprintf("servico-b answered %d", status))
+}
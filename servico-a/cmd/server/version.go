@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Build metadata, injected at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=...
+// -X main.dirty=true". dirty is a string because -X only sets strings.
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+	dirty     = "false"
+)
+
+// buildDirty reports whether the binary was built from a working tree with
+// uncommitted changes.
+func buildDirty() bool {
+	d, _ := strconv.ParseBool(dirty)
+	return d
+}
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	Dirty     bool   `json:"dirty"`
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(r.Context(), w, http.StatusOK, VersionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		Dirty:     buildDirty(),
+	})
+}
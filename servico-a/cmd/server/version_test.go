@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuildDirty(t *testing.T) {
+	previous := dirty
+	t.Cleanup(func() { dirty = previous })
+
+	tests := []struct {
+		ldflag string
+		want   bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"", false},
+		{"not-a-bool", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ldflag, func(t *testing.T) {
+			dirty = tt.ldflag
+
+			rec := httptest.NewRecorder()
+			handleVersion(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+			var got VersionResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Dirty != tt.want {
+				t.Errorf("/version dirty = %v, want %v", got.Dirty, tt.want)
+			}
+
+			res, err := newResource(context.Background(), "servico-a")
+			if err != nil {
+				t.Fatalf("newResource: %v", err)
+			}
+			if attr, _ := res.Set().Value("build.dirty"); attr.AsBool() != tt.want {
+				t.Errorf("build.dirty resource attribute = %s, want %v", attr.Emit(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationScope names the tracer and meter of this service.
+const instrumentationScope = "servico-b"
+
+// app holds the instrumentation and dependencies shared by the handlers and
+// their helpers. It is built once in main, after the tracer provider is
+// installed.
+type app struct {
+	tracer   trace.Tracer
+	resolver CEPResolver
+}
+
+func newApp(resolver CEPResolver) *app {
+	return &app{
+		tracer:   otel.Tracer(instrumentationScope, trace.WithInstrumentationVersion(version)),
+		resolver: resolver,
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the weather provider while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("weather provider circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calling a failing dependency. It opens after
+// failureThreshold consecutive transient failures (see
+// breakerFailure), fails fast for the cooldown period and then lets a
+// single probe through (half-open): a successful probe closes the
+// circuit again, a failed one reopens it.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	state            breakerState
+	failures         int
+	openedAt         time.Time
+	probing          bool
+}
+
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// Do runs fn unless the circuit is open, recording its outcome.
+func (cb *circuitBreaker) Do(fn func() error) error {
+	if err := cb.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	cb.record(err)
+	return err
+}
+
+// State returns the current breaker state.
+func (cb *circuitBreaker) State() breakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.state = breakerHalfOpen
+		cb.probing = true
+	case breakerHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// breakerFailure reports whether err counts against the breaker. Only the
+// transient failures that are also retried do: network errors, timeouts,
+// 5xx and 429. An unknown city is the client's mistake and a cancelled
+// request is the caller leaving; neither says the provider is unhealthy.
+func breakerFailure(err error) bool {
+	return !errors.Is(err, context.Canceled) && retryable(err)
+}
+
+// record updates the state with the outcome of a call. Errors that are not
+// breaker failures leave the state as it was.
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+	if err != nil && !breakerFailure(err) {
+		return
+	}
+	if err == nil {
+		cb.state = breakerClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.state = breakerOpen
+		cb.openedAt = time.Now()
+	}
+}
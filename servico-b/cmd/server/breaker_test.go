@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	const threshold = 3
+	cb := newCircuitBreaker(threshold, time.Minute)
+
+	notFound := &WeatherAPIError{Code: weatherAPINoLocationCode, Message: "No matching location found."}
+	canceled := fmt.Errorf("weather request: %w", context.Canceled)
+	for i := 0; i < 2*threshold; i++ {
+		for _, err := range []error{notFound, ErrLocationNotFound, canceled} {
+			if got := cb.Do(func() error { return err }); !errors.Is(got, err) {
+				t.Fatalf("Do returned %v, want %v", got, err)
+			}
+		}
+	}
+	if state := cb.State(); state != breakerClosed {
+		t.Fatalf("state after client errors = %s, want closed", state)
+	}
+
+	unavailable := &upstreamStatusError{StatusCode: http.StatusServiceUnavailable, err: errors.New("weather API returned status 503")}
+	for i := 0; i < threshold; i++ {
+		cb.Do(func() error { return unavailable })
+	}
+	if state := cb.State(); state != breakerOpen {
+		t.Fatalf("state after %d upstream failures = %s, want open", threshold, state)
+	}
+	if err := cb.Do(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Do while open = %v, want ErrCircuitOpen", err)
+	}
+}
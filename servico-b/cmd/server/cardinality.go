@@ -0,0 +1,37 @@
+package main
+
+import "sync"
+
+// otherCityLabel is the bucket used once the distinct-city cap is reached.
+const otherCityLabel = "other"
+
+// cityLabelLimiter guards metric labels built from city names. Only the first
+// max distinct cities are used as-is; any city seen after the cap is reached
+// is reported as "other" so the metrics backend cardinality stays bounded.
+type cityLabelLimiter struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newCityLabelLimiter(max int) *cityLabelLimiter {
+	return &cityLabelLimiter{
+		max:  max,
+		seen: make(map[string]struct{}),
+	}
+}
+
+// Label returns the value to use as the city label for a metric.
+func (l *cityLabelLimiter) Label(city string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[city]; ok {
+		return city
+	}
+	if len(l.seen) >= l.max {
+		return otherCityLabel
+	}
+	l.seen[city] = struct{}{}
+	return city
+}
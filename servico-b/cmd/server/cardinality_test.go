@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestCityLabelLimiter(t *testing.T) {
+	limiter := newCityLabelLimiter(2)
+
+	tests := []struct {
+		city string
+		want string
+	}{
+		{"São Paulo", "São Paulo"},
+		{"Recife", "Recife"},
+		{"Curitiba", otherCityLabel},
+		{"Manaus", otherCityLabel},
+		// Cities seen before the cap keep their own label.
+		{"São Paulo", "São Paulo"},
+		{"Recife", "Recife"},
+	}
+	for _, tt := range tests {
+		if got := limiter.Label(tt.city); got != tt.want {
+			t.Errorf("Label(%q) = %q, want %q", tt.city, got, tt.want)
+		}
+	}
+}
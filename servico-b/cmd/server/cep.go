@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Reasons returned by validateCEP.
+var (
+	errCEPLength   = errors.New("cep must have exactly 8 digits")
+	errCEPNonDigit = errors.New("cep must contain only digits")
+	errCEPRange    = errors.New("cep is outside the assigned range")
+)
+
+// Errors returned by a CEPResolver when the CEP is rejected or unknown, or
+// known without a locality to look the weather up for.
+var (
+	ErrInvalidZipcode      = errors.New("invalid zipcode")
+	ErrZipcodeNotFound     = errors.New("can not find zipcode")
+	ErrLocalityUnavailable = errors.New("locality unavailable for zipcode")
+)
+
+// normalizeCEP strips the formatting clients commonly send along with the
+// digits, such as surrounding spaces or the "01310-100" hyphen.
+func normalizeCEP(cep string) string {
+	return strings.Replace(strings.TrimSpace(cep), "-", "", 1)
+}
+
+// validateCEP reports why cep is not a valid CEP, or nil when it is.
+func validateCEP(cep string) error {
+	if len(cep) != 8 {
+		return errCEPLength
+	}
+	for _, char := range cep {
+		if char < '0' || char > '9' {
+			return errCEPNonDigit
+		}
+	}
+	// Cheap pre-check before any upstream round trip: no CEP is assigned
+	// below 01000-000, so this also rejects "00000000".
+	if strings.HasPrefix(cep, "00") {
+		return errCEPRange
+	}
+	return nil
+}
+
+// maxCEPBodyBytes bounds the JSON body read by cepFromRequest.
+const maxCEPBodyBytes = 1 << 10
+
+// cepFromRequest returns the CEP from the X-CEP header sent by servico-a or,
+// when the header is absent, from a {"cep": "..."} JSON body so the endpoint
+// can also be called directly. An empty string means neither was provided.
+func cepFromRequest(r *http.Request) string {
+	if cep := r.Header.Get("X-CEP"); cep != "" {
+		return cep
+	}
+	var body struct {
+		CEP string `json:"cep"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxCEPBodyBytes)).Decode(&body); err != nil {
+		return ""
+	}
+	return body.CEP
+}
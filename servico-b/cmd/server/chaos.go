@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errChaosInjected reads like a real upstream failure, so clients take their
+// usual error path.
+var errChaosInjected = errors.New("upstream request failed: connection reset by peer")
+
+// chaosInjector fails a fraction of requests on purpose to exercise alerting
+// and error handling. The random source is seeded explicitly so a run can be
+// reproduced.
+type chaosInjector struct {
+	mu   sync.Mutex
+	rng  *rand.Rand
+	rate float64
+}
+
+// chaos is nil unless CHAOS_ENABLED=true.
+var chaos *chaosInjector
+
+func newChaosInjector(rate float64, seed int64) *chaosInjector {
+	return &chaosInjector{rng: rand.New(rand.NewSource(seed)), rate: rate}
+}
+
+// Inject returns errChaosInjected for a fraction rate of calls, recording a
+// "chaos.injected" event on the span in ctx. A nil injector never fails.
+func (c *chaosInjector) Inject(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	roll := c.rng.Float64()
+	c.mu.Unlock()
+	if roll >= c.rate {
+		return nil
+	}
+	trace.SpanFromContext(ctx).AddEvent("chaos.injected", trace.WithAttributes(
+		attribute.Float64("chaos.failure_rate", c.rate),
+	))
+	return errChaosInjected
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCityLength bounds the city names accepted by POST /temperature/city.
+const maxCityLength = 100
+
+// Reasons returned by validateCity.
+var (
+	errCityEmpty      = errors.New("city is required")
+	errCityLength     = fmt.Errorf("city must have at most %d characters", maxCityLength)
+	errCityCharacters = errors.New("city may only contain letters, spaces, hyphens, apostrophes and dots")
+)
+
+// normalizeCity trims the name and collapses runs of whitespace.
+func normalizeCity(city string) string {
+	return strings.Join(strings.Fields(city), " ")
+}
+
+// validateCity reports why city cannot be sent to the weather provider, or
+// nil when it can. Only characters found in place names are allowed, so the
+// name never carries URL syntax such as "/", "?" or "&".
+func validateCity(city string) error {
+	if city == "" {
+		return errCityEmpty
+	}
+	if len([]rune(city)) > maxCityLength {
+		return errCityLength
+	}
+	for _, char := range city {
+		switch {
+		case unicode.IsLetter(char), unicode.Is(unicode.Mn, char):
+		case char == ' ', char == '-', char == '\'', char == '.':
+		default:
+			return errCityCharacters
+		}
+	}
+	return nil
+}
+
+// cityFromRequest returns the city of a {"city": "..."} JSON body, or an
+// empty string when there is none.
+func cityFromRequest(r *http.Request) string {
+	var body struct {
+		City string `json:"city"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxCEPBodyBytes)).Decode(&body); err != nil {
+		return ""
+	}
+	return body.City
+}
+
+// handleCityTemperature answers POST /temperature/city, looking the weather
+// up by city name without resolving a CEP through ViaCEP first. The
+// response cache is keyed by CEP and is not used here.
+func (a *app) handleCityTemperature(w http.ResponseWriter, r *http.Request) {
+	ctx, span := a.tracer.Start(r.Context(), "servico-b.handleCityTemperature",
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
+	defer span.End()
+	if dryRun {
+		span.SetAttributes(dryRunAttribute)
+	}
+
+	var providers *providerLog
+	if includeProviders {
+		ctx, providers = withProviderLog(ctx)
+	}
+
+	city := normalizeCity(cityFromRequest(r))
+	if err := validateCity(city); err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.String("geo.locality", city))
+
+	opts, err := parseWeatherOptions(r.URL.Query())
+	if err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.String("weather.lang", opts.Lang()))
+	ctx = withWeatherOptions(ctx, opts)
+
+	precision, err := parsePrecision(r.URL.Query())
+	if err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := chaos.Inject(ctx); err != nil {
+		span.RecordError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tempC, _, err := a.getTemperature(ctx, city)
+	if err != nil {
+		span.RecordError(err)
+		a.writeWeatherError(ctx, w, err)
+		return
+	}
+	span.SetAttributes(attribute.Float64("weather.temp_c", roundTemperature(tempC)))
+
+	response := TemperatureResponse{
+		City:  city,
+		TempC: roundTo(tempC, precision),
+		TempF: roundTo(celsiusToFahrenheit(tempC), precision),
+		TempK: roundTo(celsiusToKelvin(tempC), precision),
+	}
+	if providers != nil {
+		response.Providers = providers.Outcomes()
+	}
+
+	a.writeJSON(ctx, w, http.StatusOK, response)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressMinSize is the smallest response body, in bytes, that is
+// gzipped. Smaller bodies, such as the usual error objects, are sent as is:
+// the gzip framing would make them bigger.
+const defaultCompressMinSize = 512
+
+// compressResponses gzips response bodies of at least minSize bytes for
+// clients that send "Accept-Encoding: gzip". The body is buffered until it
+// reaches minSize or the handler returns, so small responses keep their
+// original form. Responses that already carry a Content-Encoding, such as
+// the gzipped /metrics scrape, are left untouched.
+func compressResponses(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			defer gw.finish()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, that
+// is, lists gzip or "*" without q=0.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the status and the first minSize bytes of
+// the body, then either starts gzipping or passes everything through.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status != 0 || g.gz != nil || g.passthrough {
+		return
+	}
+	g.status = status
+	// These responses have no body to compress.
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		g.passthrough = true
+		g.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	switch {
+	case g.gz != nil:
+		return g.gz.Write(p)
+	case g.passthrough:
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf = append(g.buf, p...)
+	if len(g.buf) < g.minSize {
+		return len(p), nil
+	}
+	if err := g.start(g.Header().Get("Content-Encoding") == ""); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the held status and body, compressed or not.
+func (g *gzipResponseWriter) start(compress bool) error {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if compress {
+		g.Header().Set("Content-Encoding", "gzip")
+		// The length of the compressed body is not known up front.
+		g.Header().Del("Content-Length")
+		g.ResponseWriter.WriteHeader(g.status)
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+		_, err := g.gz.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+
+	g.passthrough = true
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+// finish flushes a body that stayed below minSize uncompressed, or closes
+// the gzip stream.
+func (g *gzipResponseWriter) finish() {
+	switch {
+	case g.gz != nil:
+		g.gz.Close()
+	case g.passthrough:
+	case g.status != 0 || len(g.buf) > 0:
+		g.start(false)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the startup settings of servico-b. Values come from the
+// defaults below, then from the YAML or JSON file named by CONFIG_FILE, and
+// finally from the environment variables, which take precedence.
+type Config struct {
+	ServiceName                  string        `yaml:"service_name"`
+	CollectorEndpoints           []string      `yaml:"collector_endpoints"`
+	SDKDisabled                  bool          `yaml:"sdk_disabled"`
+	DialBlocking                 bool          `yaml:"dial_blocking"`
+	Propagators                  []string      `yaml:"propagators"`
+	TraceSampleRatio             float64       `yaml:"trace_sample_ratio"`
+	TraceIgnoreRoutes            []string      `yaml:"trace_ignore_routes"`
+	TraceShutdownTimeout         time.Duration `yaml:"trace_shutdown_timeout"`
+	BSPMaxQueueSize              int           `yaml:"bsp_max_queue_size"`
+	BSPMaxExportBatchSize        int           `yaml:"bsp_max_export_batch_size"`
+	BSPExportTimeout             time.Duration `yaml:"bsp_export_timeout"`
+	BSPScheduleDelay             time.Duration `yaml:"bsp_schedule_delay"`
+	HTTPPort                     string        `yaml:"http_port"`
+	HTTPAddr                     string        `yaml:"http_addr"`
+	ViaCEPBaseURL                string        `yaml:"viacep_base_url"`
+	WeatherProvider              string        `yaml:"weather_provider"`
+	WeatherAPIBaseURL            string        `yaml:"weatherapi_base_url"`
+	WeatherAPIKey                string        `yaml:"weather_api_key"`
+	WeatherAPIKeyFile            string        `yaml:"weather_api_key_file"`
+	OpenWeatherMapBaseURL        string        `yaml:"openweathermap_base_url"`
+	OpenWeatherMapAPIKey         string        `yaml:"openweathermap_api_key"`
+	FailOnMissingKey             bool          `yaml:"fail_on_missing_key"`
+	DryRun                       bool          `yaml:"dry_run"`
+	ChaosEnabled                 bool          `yaml:"chaos_enabled"`
+	ChaosFailureRate             float64       `yaml:"chaos_failure_rate"`
+	ChaosSeed                    int64         `yaml:"chaos_seed"`
+	WeatherMaxAttempts           int           `yaml:"weather_max_attempts"`
+	UpstreamMaxConnsPerHost      int           `yaml:"upstream_max_conns_per_host"`
+	UpstreamTimeout              time.Duration `yaml:"upstream_timeout"`
+	UpstreamMaxRedirects         int           `yaml:"upstream_max_redirects"`
+	UpstreamRedirectAllowedHosts []string      `yaml:"upstream_redirect_allowed_hosts"`
+	TempCacheTTL                 time.Duration `yaml:"temp_cache_ttl"`
+	CEPCacheMax                  int           `yaml:"cep_cache_max"`
+	UpstreamHistogramBuckets     []float64     `yaml:"upstream_histogram_buckets"`
+	UserAgent                    string        `yaml:"user_agent"`
+	CompressMinSize              int           `yaml:"compress_min_size"`
+	UpstreamMaxResponseBytes     int           `yaml:"upstream_max_response_bytes"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		ServiceName:              defaultServiceName("servico-b"),
+		Propagators:              []string{"tracecontext", "baggage"},
+		CollectorEndpoints:       []string{"otel-collector:4317"},
+		DialBlocking:             true,
+		TraceSampleRatio:         1,
+		TraceShutdownTimeout:     5 * time.Second,
+		BSPMaxQueueSize:          sdktrace.DefaultMaxQueueSize,
+		BSPMaxExportBatchSize:    sdktrace.DefaultMaxExportBatchSize,
+		BSPExportTimeout:         sdktrace.DefaultExportTimeout * time.Millisecond,
+		BSPScheduleDelay:         sdktrace.DefaultScheduleDelay * time.Millisecond,
+		HTTPPort:                 ":8081",
+		ViaCEPBaseURL:            defaultViaCEPBaseURL,
+		WeatherProvider:          "weatherapi",
+		WeatherAPIBaseURL:        defaultWeatherAPIBaseURL,
+		OpenWeatherMapBaseURL:    defaultOpenWeatherMapBaseURL,
+		WeatherMaxAttempts:       3,
+		UpstreamTimeout:          defaultUpstreamTimeout,
+		UpstreamMaxRedirects:     defaultMaxRedirects,
+		TempCacheTTL:             60 * time.Second,
+		CEPCacheMax:              10000,
+		UpstreamHistogramBuckets: defaultUpstreamBuckets,
+		UserAgent:                defaultUserAgent,
+		CompressMinSize:          defaultCompressMinSize,
+		UpstreamMaxResponseBytes: defaultMaxUpstreamResponseBytes,
+	}
+}
+
+// defaultServiceName derives the service name from the binary name, so a
+// binary reused under another name reports traces under that name. The
+// names produced by "go run" and by a plain "go build ./cmd/server" ("main"
+// and "server") say nothing about the service, so they fall back to
+// fallback, as does an empty name.
+func defaultServiceName(fallback string) string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	switch name {
+	case "", ".", string(filepath.Separator), "main", "server":
+		return fallback
+	}
+	return name
+}
+
+// loadConfig builds the Config, failing when CONFIG_FILE is set but cannot
+// be read or parsed, or when UPSTREAM_HISTOGRAM_BUCKETS is malformed.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+		}
+		// JSON is valid YAML, so one decoder handles both formats.
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+		}
+	}
+
+	cfg.ServiceName = getEnv("OTEL_SERVICE_NAME", cfg.ServiceName)
+	cfg.CollectorEndpoints = getEnvList("OTEL_EXPORTER_OTLP_ENDPOINT", cfg.CollectorEndpoints)
+	if value := os.Getenv("OTEL_SDK_DISABLED"); value != "" {
+		cfg.SDKDisabled = value == "true"
+	}
+	if value := os.Getenv("OTEL_DIAL_BLOCKING"); value != "" {
+		cfg.DialBlocking = value == "true"
+	}
+	cfg.TraceSampleRatio = math.Min(math.Max(getEnvFloat("TRACE_SAMPLE_RATIO", cfg.TraceSampleRatio), 0), 1)
+	cfg.TraceIgnoreRoutes = getEnvList("TRACE_IGNORE_ROUTES", cfg.TraceIgnoreRoutes)
+	cfg.Propagators = getEnvList("OTEL_PROPAGATORS", cfg.Propagators)
+	for _, name := range cfg.Propagators {
+		if _, ok := propagators[name]; !ok {
+			return Config{}, fmt.Errorf("unknown propagator %q in OTEL_PROPAGATORS", name)
+		}
+	}
+	cfg.TraceShutdownTimeout = getEnvDuration("TRACE_SHUTDOWN_TIMEOUT", cfg.TraceShutdownTimeout)
+	cfg.BSPMaxQueueSize = max(getEnvInt("OTEL_BSP_MAX_QUEUE_SIZE", cfg.BSPMaxQueueSize), 1)
+	// The SDK would silently cap the batch at the queue size anyway.
+	cfg.BSPMaxExportBatchSize = min(max(getEnvInt("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", cfg.BSPMaxExportBatchSize), 1), cfg.BSPMaxQueueSize)
+	cfg.BSPExportTimeout = getEnvMillis("OTEL_BSP_EXPORT_TIMEOUT", cfg.BSPExportTimeout)
+	cfg.BSPScheduleDelay = getEnvMillis("OTEL_BSP_SCHEDULE_DELAY", cfg.BSPScheduleDelay)
+	cfg.HTTPPort = getEnv("HTTP_PORT", cfg.HTTPPort)
+	// HTTP_ADDR binds to a specific interface; without it the service
+	// listens on HTTP_PORT on all interfaces.
+	cfg.HTTPAddr = getEnv("HTTP_ADDR", cfg.HTTPAddr)
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = cfg.HTTPPort
+	}
+	if err := validateListenAddr(cfg.HTTPAddr); err != nil {
+		return Config{}, err
+	}
+	cfg.ViaCEPBaseURL = strings.TrimSuffix(getEnv("VIACEP_BASE_URL", cfg.ViaCEPBaseURL), "/")
+	cfg.WeatherProvider = getEnv("WEATHER_PROVIDER", cfg.WeatherProvider)
+	cfg.WeatherAPIBaseURL = strings.TrimSuffix(getEnv("WEATHERAPI_BASE_URL", cfg.WeatherAPIBaseURL), "/")
+	cfg.WeatherAPIKey = getEnv("WEATHER_API_KEY", cfg.WeatherAPIKey)
+	cfg.WeatherAPIKeyFile = getEnv("WEATHER_API_KEY_FILE", cfg.WeatherAPIKeyFile)
+	cfg.OpenWeatherMapBaseURL = strings.TrimSuffix(getEnv("OPENWEATHERMAP_BASE_URL", cfg.OpenWeatherMapBaseURL), "/")
+	cfg.OpenWeatherMapAPIKey = getEnv("OPENWEATHERMAP_API_KEY", cfg.OpenWeatherMapAPIKey)
+	if value := os.Getenv("FAIL_ON_MISSING_KEY"); value != "" {
+		cfg.FailOnMissingKey = value == "true"
+	}
+	if value := os.Getenv("DRY_RUN"); value != "" {
+		cfg.DryRun = value == "true"
+	}
+	if value := os.Getenv("CHAOS_ENABLED"); value != "" {
+		cfg.ChaosEnabled = value == "true"
+	}
+	cfg.ChaosFailureRate = math.Min(math.Max(getEnvFloat("CHAOS_FAILURE_RATE", cfg.ChaosFailureRate), 0), 1)
+	// Without a seed every run fails a different set of requests.
+	if cfg.ChaosSeed == 0 {
+		cfg.ChaosSeed = time.Now().UnixNano()
+	}
+	cfg.ChaosSeed = int64(getEnvInt("CHAOS_SEED", int(cfg.ChaosSeed)))
+	cfg.WeatherMaxAttempts = max(getEnvInt("WEATHER_MAX_ATTEMPTS", cfg.WeatherMaxAttempts), 1)
+	cfg.UpstreamMaxConnsPerHost = getEnvInt("UPSTREAM_MAX_CONNS_PER_HOST", cfg.UpstreamMaxConnsPerHost)
+	cfg.UpstreamTimeout = getEnvDuration("UPSTREAM_TIMEOUT", cfg.UpstreamTimeout)
+	cfg.UpstreamMaxRedirects = getEnvInt("UPSTREAM_MAX_REDIRECTS", cfg.UpstreamMaxRedirects)
+	cfg.UpstreamRedirectAllowedHosts = getEnvList("UPSTREAM_REDIRECT_ALLOWED_HOSTS", cfg.UpstreamRedirectAllowedHosts)
+	cfg.TempCacheTTL = getEnvDuration("TEMP_CACHE_TTL", cfg.TempCacheTTL)
+	cfg.CEPCacheMax = getEnvInt("CEP_CACHE_MAX", cfg.CEPCacheMax)
+	cfg.UserAgent = getEnv("HTTP_USER_AGENT", cfg.UserAgent)
+	cfg.CompressMinSize = max(getEnvInt("COMPRESS_MIN_SIZE", cfg.CompressMinSize), 0)
+	cfg.UpstreamMaxResponseBytes = max(getEnvInt("UPSTREAM_MAX_RESPONSE_BYTES", cfg.UpstreamMaxResponseBytes), 1)
+	if value := os.Getenv("UPSTREAM_HISTOGRAM_BUCKETS"); value != "" {
+		buckets, err := parseBuckets(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid UPSTREAM_HISTOGRAM_BUCKETS: %w", err)
+		}
+		cfg.UpstreamHistogramBuckets = buckets
+	}
+
+	return cfg, nil
+}
+
+// validateListenAddr checks that addr is a "host:port" (or ":port") address
+// net/http can listen on.
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port in listen address %q", addr)
+	}
+	return nil
+}
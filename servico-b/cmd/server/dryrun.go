@@ -0,0 +1,25 @@
+package main
+
+import "go.opentelemetry.io/otel/attribute"
+
+// dryRun makes searchCEP and getTemperature answer with canned data instead
+// of calling ViaCEP and the weather provider, so the tracing pipeline can be
+// load-tested without spending API quota. Spans are still created and carry
+// dryRunAttribute.
+var dryRun bool
+
+// dryRunTempC is the temperature reported in dry-run mode.
+const dryRunTempC = 25.0
+
+var dryRunAttribute = attribute.Bool("dry_run", true)
+
+// dryRunAddress is the address resolved for every CEP in dry-run mode.
+func dryRunAddress(cep string) Address {
+	return Address{
+		CEP:          cep[:5] + "-" + cep[5:],
+		Street:       "Praça da Sé",
+		Neighborhood: "Sé",
+		City:         "São Paulo",
+		State:        "SP",
+	}
+}
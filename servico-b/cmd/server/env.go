@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnv reads a string from the environment, falling back to def when the
+// variable is unset.
+func getEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvList reads a comma-separated list from the environment, trimming
+// each item and falling back to def when the variable is unset.
+func getEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvInt reads an integer from the environment, falling back to def when
+// the variable is unset or malformed.
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid environment value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return n
+}
+
+// getEnvDuration reads a duration such as "30s" from the environment, falling
+// back to def when the variable is unset or malformed.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid environment value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return d
+}
+
+// getEnvMillis reads a duration given in integer milliseconds, the format of
+// the standard OTEL_* variables, falling back to def when the variable is
+// unset or malformed.
+func getEnvMillis(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 {
+		slog.Warn("Invalid environment value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// getEnvFloat reads a float from the environment, falling back to def when
+// the variable is unset or malformed.
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("Invalid environment value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// userAgent identifies this service to upstream APIs, some of which throttle
+// Go's default agent.
+var userAgent = defaultUserAgent
+
+const defaultUserAgent = "otelgoexpert/1.0"
+
+// upstreamClient is shared by all calls to ViaCEP and the weather providers
+// so connections are pooled across requests.
+var upstreamClient = newUpstreamClient(0, defaultMaxRedirects, nil)
+
+const defaultMaxRedirects = 3
+
+// newUpstreamClient builds the shared client. maxConnsPerHost caps the
+// connections opened to each upstream host; zero means no limit. Every call
+// gets an otelhttp client span with the standard HTTP attributes, and the
+// trace context is injected into the outgoing headers. Redirects follow
+// redirectPolicy.
+func newUpstreamClient(maxConnsPerHost, maxRedirects int, allowedHosts []string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = maxConnsPerHost
+	return &http.Client{
+		Transport:     otelhttp.NewTransport(redactingTransport{base: transport}),
+		CheckRedirect: redirectPolicy(maxRedirects, allowedHosts),
+	}
+}
+
+// redactedURL is u without its query string, which carries the provider
+// API keys, and without user info. It is the form of an upstream URL that
+// may be recorded on spans.
+func redactedURL(u *url.URL) string {
+	target := *u
+	target.RawQuery = ""
+	target.User = nil
+	return target.String()
+}
+
+// redactingTransport runs inside the otelhttp transport and overwrites the
+// URL attributes otelhttp put on the client span with redactedURL.
+type redactingTransport struct {
+	base http.RoundTripper
+}
+
+func (t redactingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redacted := redactedURL(req.URL)
+	trace.SpanFromContext(req.Context()).SetAttributes(
+		attribute.String("http.url", redacted),
+		attribute.String("url.full", redacted),
+	)
+	return t.base.RoundTrip(req)
+}
+
+// doUpstream sends req with upstreamClient. The *url.Error returned for a
+// failed request quotes the full URL, so it is redacted before the error
+// is recorded on spans or logged.
+func doUpstream(req *http.Request) (*http.Response, error) {
+	resp, err := upstreamClient.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			urlErr.URL = redactedURL(u)
+		}
+	}
+	return resp, err
+}
+
+// redirectPolicy follows at most maxRedirects redirects, each recorded as an
+// "http.redirect" event on the caller's span. Redirects to a host other than
+// the one originally requested are refused unless the host is in
+// allowedHosts, so a compromised mirror cannot send requests (and API keys)
+// elsewhere.
+func redirectPolicy(maxRedirects int, allowedHosts []string) func(*http.Request, []*http.Request) error {
+	allowed := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		allowed[host] = true
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		trace.SpanFromContext(req.Context()).AddEvent("http.redirect", trace.WithAttributes(
+			attribute.String("http.redirect.url", redactedURL(req.URL)),
+			attribute.Int("http.redirect.count", len(via)),
+		))
+
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if origin := via[0].URL.Host; req.URL.Host != origin && !allowed[req.URL.Host] {
+			return fmt.Errorf("refusing redirect from %s to %s", origin, req.URL.Host)
+		}
+		return nil
+	}
+}
+
+// newUpstreamRequest builds a GET request to an upstream API carrying
+// userAgent, which is also recorded on the span in ctx. With
+// detailedHTTPTrace the connection phases are recorded on that span too.
+func newUpstreamRequest(ctx context.Context, url string) (*http.Request, error) {
+	if detailedHTTPTrace {
+		ctx = withHTTPTrace(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	trace.SpanFromContext(ctx).SetAttributes(semconv.UserAgentOriginal(userAgent))
+	return req, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestUpstreamClientRedactsAPIKeys(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	upstreamClient = newUpstreamClient(0, defaultMaxRedirects, nil)
+
+	const secret = "s3cr3t-api-key"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	req, err := newUpstreamRequest(context.Background(), server.URL+"/current.json?key="+secret+"&q=Recife")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := doUpstream(req)
+	if err != nil {
+		t.Fatalf("doUpstream: %v", err)
+	}
+	resp.Body.Close()
+
+	// A refused connection makes the client return a *url.Error.
+	server.Close()
+	req, err = newUpstreamRequest(context.Background(), server.URL+"/weather?appid="+secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doUpstream(req); err == nil {
+		t.Fatal("doUpstream to a closed server succeeded")
+	} else if strings.Contains(err.Error(), secret) {
+		t.Errorf("error %q carries the API key", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			if strings.Contains(attr.Value.Emit(), secret) {
+				t.Errorf("span %q attribute %s = %q carries the API key", span.Name(), attr.Key, attr.Value.Emit())
+			}
+		}
+		for _, event := range span.Events() {
+			for _, attr := range event.Attributes {
+				if strings.Contains(attr.Value.Emit(), secret) {
+					t.Errorf("span %q event %s attribute %s carries the API key", span.Name(), event.Name, attr.Key)
+				}
+			}
+		}
+	}
+}
+
+func TestUpstreamClientMaxConnsPerHost(t *testing.T) {
+	var active, peak, conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	client := newUpstreamClient(1, defaultMaxRedirects, nil)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 1 {
+		t.Errorf("peak concurrent requests = %d, want 1", got)
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("opened %d connections for %d requests, want 1", got, requests)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// detailedHTTPTrace adds the DNS, connect and TLS phases of upstream calls
+// as events on the calling span. It is verbose, so it is off by default.
+var detailedHTTPTrace bool
+
+// withHTTPTrace attaches an httptrace.ClientTrace to ctx that records each
+// connection phase of a request made with it as a span event on the span in
+// ctx, with the phase duration in milliseconds. Pooled connections skip the
+// phases, which "http.got_conn" shows with http.conn.reused.
+func withHTTPTrace(ctx context.Context) context.Context {
+	span := trace.SpanFromContext(ctx)
+
+	var mu sync.Mutex
+	var dnsStart, tlsStart time.Time
+	// Dialing may race several addresses (IPv4 and IPv6) at once.
+	connectStarts := make(map[string]time.Time)
+
+	since := func(start time.Time) attribute.KeyValue {
+		return attribute.Float64("duration_ms", durationMillis(time.Since(start)))
+	}
+	withError := func(attrs []attribute.KeyValue, err error) []attribute.KeyValue {
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		return attrs
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			span.AddEvent("http.get_conn", trace.WithAttributes(attribute.String("net.peer.name", hostPort)))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("http.got_conn", trace.WithAttributes(
+				attribute.Bool("http.conn.reused", info.Reused),
+				attribute.Bool("http.conn.was_idle", info.WasIdle),
+			))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+			span.AddEvent("dns.start", trace.WithAttributes(attribute.String("net.host.name", info.Host)))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			attrs := []attribute.KeyValue{since(dnsStart), attribute.Int("dns.addresses", len(info.Addrs))}
+			mu.Unlock()
+			span.AddEvent("dns.done", trace.WithAttributes(withError(attrs, info.Err)...))
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStarts[network+" "+addr] = time.Now()
+			mu.Unlock()
+			span.AddEvent("connect.start", trace.WithAttributes(
+				attribute.String("net.transport", network),
+				attribute.String("net.peer.addr", addr),
+			))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			attrs := []attribute.KeyValue{
+				since(connectStarts[network+" "+addr]),
+				attribute.String("net.transport", network),
+				attribute.String("net.peer.addr", addr),
+			}
+			mu.Unlock()
+			span.AddEvent("connect.done", trace.WithAttributes(withError(attrs, err)...))
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+			span.AddEvent("tls.handshake.start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			attrs := []attribute.KeyValue{since(tlsStart)}
+			mu.Unlock()
+			if err == nil {
+				attrs = append(attrs,
+					attribute.String("tls.version", tls.VersionName(state.Version)),
+					attribute.Bool("tls.resumed", state.DidResume),
+				)
+			}
+			span.AddEvent("tls.handshake.done", trace.WithAttributes(withError(attrs, err)...))
+		},
+	})
+}
@@ -2,121 +2,167 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/credentials"
 )
 
-type ViaCEPResponse struct {
-	Cep         string `json:"cep"`
-	Logradouro  string `json:"logradouro"`
-	Complemento string `json:"complemento"`
-	Bairro      string `json:"bairro"`
-	Localidade  string `json:"localidade"`
-	UF          string `json:"uf"`
-	Erro        bool   `json:"erro"`
-}
-
-type WeatherAPIResponse struct {
-	Location struct {
-		Name string `json:"name"`
-	} `json:"location"`
-	Current struct {
-		TempC float64 `json:"temp_c"`
-	} `json:"current"`
-}
-
 type TemperatureResponse struct {
 	City  string  `json:"city"`
 	TempC float64 `json:"temp_C"`
 	TempF float64 `json:"temp_F"`
 	TempK float64 `json:"temp_K"`
+
+	// Address details, only present for ?verbose=true.
+	Logradouro string `json:"logradouro,omitempty"`
+	Bairro     string `json:"bairro,omitempty"`
+	UF         string `json:"uf,omitempty"`
+
+	Providers []ProviderOutcome `json:"providers,omitempty"`
 }
 
-func initProvider(serviceName, collectorURL string) (func(context.Context) error, error) {
-	ctx := context.Background()
+// forRequest rounds the temperatures to precision decimal places and drops
+// the address details unless verbose is set.
+func (r TemperatureResponse) forRequest(verbose bool, precision int) TemperatureResponse {
+	r.TempC = roundTo(r.TempC, precision)
+	r.TempF = roundTo(r.TempF, precision)
+	r.TempK = roundTo(r.TempK, precision)
+	if !verbose {
+		r.Logradouro, r.Bairro, r.UF = "", "", ""
+	}
+	return r
+}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-		),
-	)
+// weatherProvider is the WeatherProvider selected at startup.
+var weatherProvider WeatherProvider
+
+// verifyUF enables comparing the ViaCEP UF with the region reported by the
+// weather provider; ufMismatchAction is "warn" (span event only) or "reject".
+var (
+	verifyUF         bool
+	ufMismatchAction string
+)
+
+// weatherMaxAttempts bounds how many times getTemperature calls the weather
+// provider for a single request, including the first attempt.
+var weatherMaxAttempts int
+
+// includeProviders adds the list of provider calls made for a request to
+// its response.
+var includeProviders bool
+
+// weatherBreaker guards calls to weatherProvider.
+var weatherBreaker *circuitBreaker
+
+// cityLabels bounds the cardinality of city names used as metric labels.
+var cityLabels *cityLabelLimiter
+
+// temperatureResponses caches successful responses by CEP; nil when
+// TEMP_CACHE_TTL is zero.
+var temperatureResponses *temperatureCache
+
+func initProvider(ctx context.Context, cfg Config, creds credentials.TransportCredentials) (func(context.Context) error, error) {
+	res, err := newResource(ctx, cfg.ServiceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Retry logic para conectar ao collector
-	var conn *grpc.ClientConn
-	maxRetries := 20
-	retryDelay := 2 * time.Second
-	
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		var err error
-		conn, err = grpc.DialContext(ctx, collectorURL,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithBlock(),
-		)
-		cancel()
-		
-		if err == nil {
-			log.Printf("Successfully connected to OTEL collector after %d attempts", i+1)
-			break
-		}
-		
-		if i < maxRetries-1 {
-			log.Printf("Failed to connect to collector (attempt %d/%d): %v. Retrying in %v...", i+1, maxRetries, err, retryDelay)
-			time.Sleep(retryDelay)
-		} else {
-			return nil, fmt.Errorf("failed to create gRPC connection to collector after %d attempts: %w", maxRetries, err)
-		}
+	// The Prometheus reader does not depend on the collector, so /metrics
+	// keeps serving the app metrics when the OTLP pipeline is down or
+	// disabled, which is when scraping them directly matters most.
+	promExporter, err := prometheus.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+	// Histogram observations made under a sampled span keep its trace ID as
+	// an exemplar, linking a latency bucket to an example trace.
+	// OTEL_METRICS_EXEMPLAR_FILTER overrides the filter.
+	meterOptions := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+		sdkmetric.WithReader(promExporter),
+		sdkmetric.WithView(upstreamHistogramView(cfg.UpstreamHistogramBuckets)),
+	}
+
+	// OTEL_SDK_DISABLED skips the collector entirely; handlers keep creating
+	// spans, which the noop provider discards.
+	if cfg.SDKDisabled {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		meterProvider := sdkmetric.NewMeterProvider(meterOptions...)
+		otel.SetMeterProvider(meterProvider)
+		slog.Info("OpenTelemetry SDK disabled")
+		return meterProvider.Shutdown, nil
+	}
+
+	conn, err := dialCollector(ctx, cfg.CollectorEndpoints, creds, cfg.DialBlocking)
+	if err != nil {
+		otel.SetMeterProvider(sdkmetric.NewMeterProvider(meterOptions...))
+		return nil, err
 	}
 
-	traceExporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithGRPCConn(conn))
+	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
+	bsp := sdktrace.NewBatchSpanProcessor(traceExporter,
+		sdktrace.WithMaxQueueSize(cfg.BSPMaxQueueSize),
+		sdktrace.WithMaxExportBatchSize(cfg.BSPMaxExportBatchSize),
+		sdktrace.WithExportTimeout(cfg.BSPExportTimeout),
+		sdktrace.WithBatchTimeout(cfg.BSPScheduleDelay),
+	)
+	slog.Info("Batch span processor configured",
+		"max_queue_size", cfg.BSPMaxQueueSize,
+		"max_export_batch_size", cfg.BSPMaxExportBatchSize,
+		"export_timeout", cfg.BSPExportTimeout.String(),
+		"schedule_delay", cfg.BSPScheduleDelay.String(),
+	)
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(newSampler(cfg.TraceSampleRatio, cfg.TraceIgnoreRoutes)),
 		sdktrace.WithResource(res),
 		sdktrace.WithSpanProcessor(bsp),
 	)
 	otel.SetTracerProvider(tracerProvider)
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTextMapPropagator(newPropagator(cfg.Propagators))
 
-	return tracerProvider.Shutdown, nil
-}
-
-func validateCEP(cep string) bool {
-	if len(cep) != 8 {
-		return false
-	}
-	for _, char := range cep {
-		if char < '0' || char > '9' {
-			return false
-		}
+	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
 	}
-	return true
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		append(meterOptions, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))...,
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tracerProvider.Shutdown(ctx), meterProvider.Shutdown(ctx))
+	}, nil
 }
 
 func celsiusToFahrenheit(c float64) float64 {
@@ -124,150 +170,260 @@ func celsiusToFahrenheit(c float64) float64 {
 }
 
 func celsiusToKelvin(c float64) float64 {
-	return c + 273
+	return c + 273.15
 }
 
-func searchCEP(ctx context.Context, cep string) (*ViaCEPResponse, error) {
-	tracer := otel.Tracer("servico-b")
-	ctx, span := tracer.Start(ctx, "servico-b.searchCEP")
-	defer span.End()
+// kelvinToCelsius lets providers that only report Kelvin feed the same
+// Celsius-based conversion pipeline.
+func kelvinToCelsius(k float64) float64 {
+	return k - 273.15
+}
 
-	url := fmt.Sprintf("https://viacep.com.br/ws/%s/json/", cep)
-	
-	span.SetAttributes(
-		semconv.HTTPMethod("GET"),
-		semconv.HTTPURL(url),
-	)
-	
-	startTime := time.Now()
-	resp, err := http.Get(url)
-	duration := time.Since(startTime)
-	
-	if err != nil {
-		span.RecordError(err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	span.SetAttributes(
-		semconv.HTTPStatusCode(resp.StatusCode),
-	)
+// defaultPrecision and maxPrecision bound the ?precision= query parameter,
+// the number of decimal places of the temperatures in a response.
+const (
+	defaultPrecision = 1
+	maxPrecision     = 3
+)
 
-	if resp.StatusCode == http.StatusBadRequest {
-		return nil, fmt.Errorf("invalid zipcode")
+// parsePrecision reads precision from the request query, defaulting to
+// defaultPrecision and rejecting values outside 0..maxPrecision.
+func parsePrecision(query url.Values) (int, error) {
+	value := query.Get("precision")
+	if value == "" {
+		return defaultPrecision, nil
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	precision, err := strconv.Atoi(value)
+	if err != nil || precision < 0 || precision > maxPrecision {
+		return 0, fmt.Errorf("precision must be an integer between 0 and %d, got %q", maxPrecision, value)
 	}
+	return precision, nil
+}
 
-	var viaCEPResp ViaCEPResponse
-	if err := json.Unmarshal(body, &viaCEPResp); err != nil {
-		return nil, err
-	}
+// roundTo rounds t to precision decimal places, hiding float artifacts such
+// as 300.45999999 from clients.
+func roundTo(t float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Round(t*scale) / scale
+}
 
-	if viaCEPResp.Erro {
-		return nil, fmt.Errorf("can not find zipcode")
-	}
+// roundTemperature rounds to the default precision. It is used for span
+// attributes, which do not depend on the request.
+func roundTemperature(t float64) float64 {
+	return roundTo(t, defaultPrecision)
+}
 
-	log.Printf("CEP search took %v", duration)
+// searchCEP resolves cep through the app's CEPResolver, or to a canned
+// address in dry-run mode.
+func (a *app) searchCEP(ctx context.Context, cep string) (Address, error) {
+	ctx, span := a.tracer.Start(ctx, "servico-b.searchCEP")
+	defer span.End()
+
+	if dryRun {
+		span.SetAttributes(dryRunAttribute)
+		return dryRunAddress(cep), nil
+	}
 
-	return &viaCEPResp, nil
+	address, err := a.resolver.Resolve(ctx, cep)
+	if err != nil {
+		span.RecordError(err)
+		return Address{}, err
+	}
+	return address, nil
 }
 
-func getTemperature(ctx context.Context, city string) (float64, error) {
-	tracer := otel.Tracer("servico-b")
-	ctx, span := tracer.Start(ctx, "servico-b.getTemperature")
+// getTemperature returns the current temperature in Celsius for city and,
+// when the provider reports it, the region the city was resolved to.
+func (a *app) getTemperature(ctx context.Context, city string) (float64, string, error) {
+	ctx, span := a.tracer.Start(ctx, "servico-b.getTemperature")
 	defer span.End()
 
-	weatherAPIKey := os.Getenv("WEATHER_API_KEY")
-	if weatherAPIKey == "" {
-		return 0, fmt.Errorf("WEATHER_API_KEY not set")
+	if dryRun {
+		span.SetAttributes(
+			dryRunAttribute,
+			attribute.Float64("weather.temp_c", dryRunTempC),
+			attribute.String("geo.locality", city),
+		)
+		return dryRunTempC, "", nil
 	}
 
-	// URL encode a cidade para evitar problemas com espaços e caracteres especiais
-	encodedCity := url.QueryEscape(city)
-	url := fmt.Sprintf("http://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no", weatherAPIKey, encodedCity)
-	
-	span.SetAttributes(
-		semconv.HTTPMethod("GET"),
-		semconv.HTTPURL(url),
-	)
-	
-	startTime := time.Now()
-	resp, err := http.Get(url)
-	duration := time.Since(startTime)
-	
+	span.SetAttributes(attribute.String("weather.provider", weatherProvider.Name()))
+
+	var tempC float64
+	var region string
+	err := weatherBreaker.Do(func() error {
+		for attempt := 1; ; attempt++ {
+			attemptCtx, attemptSpan := a.tracer.Start(ctx, "getTemperature.attempt",
+				trace.WithAttributes(attribute.Int("weather.attempt", attempt)),
+			)
+			attemptCtx, cancel := context.WithTimeout(attemptCtx, upstreamTimeout)
+			startTime := time.Now()
+			var err error
+			if regional, ok := weatherProvider.(RegionalWeatherProvider); ok {
+				tempC, region, err = regional.TemperatureWithRegion(attemptCtx, city)
+			} else {
+				tempC, err = weatherProvider.Temperature(attemptCtx, city)
+			}
+			cancel()
+			duration := time.Since(startTime)
+			recordUpstreamDuration(attemptCtx, weatherProvider.Name(), duration,
+				attribute.String("geo.city", cityLabels.Label(city)),
+			)
+			addServerTiming(ctx, "weather", duration)
+			recordProvider(ctx, ProviderOutcome{
+				Kind:    "weather",
+				Name:    weatherProvider.Name(),
+				Attempt: attempt,
+				Outcome: providerOutcomeName(err),
+			})
+			if err == nil {
+				attemptSpan.SetAttributes(attribute.String("weather.attempt.status", "ok"))
+				attemptSpan.End()
+				return nil
+			}
+
+			attemptSpan.RecordError(err)
+			attemptSpan.SetStatus(codes.Error, err.Error())
+			if errors.Is(err, ErrRateLimited) {
+				recordRateLimited(attemptCtx, weatherProvider.Name())
+			}
+			if attempt >= weatherMaxAttempts || !retryable(err) {
+				attemptSpan.SetAttributes(attribute.String("weather.attempt.status", "failed"))
+				attemptSpan.End()
+				return err
+			}
+			delay := retryDelay(attempt, err)
+			attemptSpan.SetAttributes(
+				attribute.String("weather.attempt.status", "retry"),
+				attribute.Int64("weather.retry_in_ms", delay.Milliseconds()),
+			)
+			attemptSpan.End()
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	span.SetAttributes(attribute.String("weather.circuit_breaker.state", weatherBreaker.State().String()))
+	if errors.Is(err, ErrCircuitOpen) {
+		recordProvider(ctx, ProviderOutcome{Kind: "weather", Name: weatherProvider.Name(), Outcome: providerOutcomeName(err)})
+	}
 	if err != nil {
 		span.RecordError(err)
-		return 0, err
+		return 0, "", err
 	}
-	defer resp.Body.Close()
-	
 	span.SetAttributes(
-		semconv.HTTPStatusCode(resp.StatusCode),
+		attribute.Float64("weather.temp_c", roundTemperature(tempC)),
+		attribute.String("geo.locality", city),
 	)
+	return tempC, region, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Weather API error response: %s", string(body))
-		return 0, fmt.Errorf("weather API returned status %d: %s", resp.StatusCode, string(body))
+func (a *app) handleTemperature(w http.ResponseWriter, r *http.Request) {
+	// Besides being the parent of the server span, servico-a's span is linked
+	// explicitly so the relationship survives a future asynchronous
+	// (queue-based) hand-off.
+	remote := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(r.Header))
+	ctx, span := a.tracer.Start(r.Context(), "servico-b.handleTemperature",
+		trace.WithLinks(trace.LinkFromContext(remote)),
+		trace.WithAttributes(requestIDAttribute(r.Context())),
+	)
+	defer span.End()
+	if dryRun {
+		span.SetAttributes(dryRunAttribute)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
+	if fingerprint := baggage.FromContext(ctx).Member("request.fingerprint").Value(); fingerprint != "" {
+		span.SetAttributes(attribute.String("request.fingerprint", fingerprint))
 	}
 
-	var weatherResp WeatherAPIResponse
-	if err := json.Unmarshal(body, &weatherResp); err != nil {
-		return 0, err
+	var providers *providerLog
+	if includeProviders {
+		ctx, providers = withProviderLog(ctx)
 	}
 
-	log.Printf("Temperature search took %v", duration)
-
-	return weatherResp.Current.TempC, nil
-}
-
-func handleTemperature(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	tracer := otel.Tracer("servico-b")
-	
-	ctx, span := tracer.Start(ctx, "servico-b.handleTemperature")
-	defer span.End()
-
-	cep := r.Header.Get("X-CEP")
+	cep := normalizeCEP(cepFromRequest(r))
 	if cep == "" {
 		span.RecordError(fmt.Errorf("CEP not provided"))
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "CEP header is required"})
+		a.writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": "CEP is required in the X-CEP header or the JSON body"})
+		return
+	}
+
+	opts, err := parseWeatherOptions(r.URL.Query())
+	if err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.String("weather.lang", opts.Lang()))
+	ctx = withWeatherOptions(ctx, opts)
+
+	precision, err := parsePrecision(r.URL.Query())
+	if err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	ctx, validateSpan := tracer.Start(ctx, "servico-b.validateCEP")
-	isValid := validateCEP(cep)
+	ctx, validateSpan := a.tracer.Start(ctx, "servico-b.validateCEP")
+	err = validateCEP(cep)
+	if errors.Is(err, errCEPRange) {
+		validateSpan.AddEvent("cep.prefilter.rejected", trace.WithAttributes(attribute.String("cep", cep)))
+	}
 	validateSpan.End()
 
-	if !isValid {
-		span.RecordError(fmt.Errorf("invalid zipcode"))
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid zipcode"})
+	if err != nil {
+		span.RecordError(err)
+		a.writeJSON(ctx, w, http.StatusUnprocessableEntity, map[string]string{"error": "invalid zipcode"})
+		return
+	}
+
+	if err := chaos.Inject(ctx); err != nil {
+		span.RecordError(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	viaCEPResp, err := searchCEP(ctx, cep)
+	verbose := r.URL.Query().Get("verbose") == "true"
+	if temperatureResponses != nil {
+		if cached, ok := temperatureResponses.Get(ctx, cep); ok {
+			span.AddEvent("cache.hit", trace.WithAttributes(
+				attribute.String("cep", cep),
+				attribute.String("geo.locality", cached.City),
+			))
+			span.SetAttributes(
+				attribute.String("geo.locality", cached.City),
+				attribute.Float64("weather.temp_c", roundTemperature(cached.TempC)),
+			)
+			a.writeJSON(ctx, w, http.StatusOK, cached.forRequest(verbose, precision))
+			return
+		}
+	}
+
+	address, err := a.searchCEP(ctx, cep)
+	recordProvider(ctx, ProviderOutcome{Kind: "cep", Name: "viacep", Outcome: providerOutcomeName(err)})
 	if err != nil {
-		if err.Error() == "invalid zipcode" {
+		if errors.Is(err, ErrInvalidZipcode) {
+			span.RecordError(err)
+			a.writeJSON(ctx, w, http.StatusUnprocessableEntity, map[string]string{"error": "invalid zipcode"})
+			return
+		}
+		if errors.Is(err, ErrZipcodeNotFound) {
 			span.RecordError(err)
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			json.NewEncoder(w).Encode(map[string]string{"error": "invalid zipcode"})
+			a.writeJSON(ctx, w, http.StatusNotFound, map[string]string{"error": "can not find zipcode"})
 			return
 		}
-		if err.Error() == "can not find zipcode" {
+		if errors.Is(err, ErrLocalityUnavailable) {
 			span.RecordError(err)
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "can not find zipcode"})
+			a.writeJSON(ctx, w, http.StatusUnprocessableEntity, map[string]string{"error": "locality unavailable for zipcode"})
+			return
+		}
+		var timeoutErr *upstreamTimeoutError
+		if errors.As(err, &timeoutErr) {
+			span.RecordError(err)
+			a.writeJSON(ctx, w, http.StatusGatewayTimeout, map[string]string{"error": "zipcode lookup timed out"})
 			return
 		}
 		span.RecordError(err)
@@ -275,80 +431,222 @@ func handleTemperature(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tempC, err := getTemperature(ctx, viaCEPResp.Localidade)
+	// The weather lookup needs the locality resolved by ViaCEP, so the two
+	// calls run sequentially; the event marks the hand-off between them.
+	span.AddEvent("cep.resolved", trace.WithAttributes(
+		attribute.String("cep", cep),
+		attribute.String("geo.locality", address.City),
+	))
+
+	span.SetAttributes(attribute.String("geo.locality", address.City))
+
+	tempC, region, err := a.getTemperature(ctx, address.City)
 	if err != nil {
 		span.RecordError(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.writeWeatherError(ctx, w, err)
 		return
 	}
+	span.SetAttributes(attribute.Float64("weather.temp_c", roundTemperature(tempC)))
+
+	if verifyUF && region != "" && !regionMatchesUF(region, address.State) {
+		span.AddEvent("location.mismatch", trace.WithAttributes(
+			attribute.String("viacep.uf", address.State),
+			attribute.String("weather.region", region),
+		))
+		if ufMismatchAction == "reject" {
+			a.writeJSON(ctx, w, http.StatusUnprocessableEntity, map[string]string{"error": "location_mismatch"})
+			return
+		}
+	}
 
-	tempF := celsiusToFahrenheit(tempC)
-	tempK := celsiusToKelvin(tempC)
-
+	// The cache keeps the unrounded temperatures, so a hit can be rounded to
+	// whatever precision the next request asks for.
 	response := TemperatureResponse{
-		City:  viaCEPResp.Localidade,
-		TempC: tempC,
-		TempF: tempF,
-		TempK: tempK,
+		City:       address.City,
+		TempC:      tempC,
+		TempF:      celsiusToFahrenheit(tempC),
+		TempK:      celsiusToKelvin(tempC),
+		Logradouro: address.Street,
+		Bairro:     address.Neighborhood,
+		UF:         address.State,
+	}
+	if temperatureResponses != nil {
+		temperatureResponses.Put(ctx, cep, response)
+	}
+
+	response = response.forRequest(verbose, precision)
+	if providers != nil {
+		response.Providers = providers.Outcomes()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	a.writeJSON(ctx, w, http.StatusOK, response)
+}
+
+// writeWeatherError answers a request whose getTemperature call failed.
+func (a *app) writeWeatherError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrCircuitOpen) {
+		a.writeJSON(ctx, w, http.StatusServiceUnavailable, map[string]string{"error": "weather service unavailable"})
+		return
+	}
+	if errors.Is(err, ErrLocationNotFound) {
+		a.writeJSON(ctx, w, http.StatusNotFound, map[string]string{"error": "can not find location"})
+		return
+	}
+	var timeoutErr *upstreamTimeoutError
+	if errors.As(err, &timeoutErr) {
+		a.writeJSON(ctx, w, http.StatusGatewayTimeout, map[string]string{"error": "weather lookup timed out"})
+		return
+	}
+	if errors.Is(err, ErrRateLimited) {
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfterHeader != "" {
+			w.Header().Set("Retry-After", statusErr.RetryAfterHeader)
+		}
+		a.writeJSON(ctx, w, http.StatusServiceUnavailable, map[string]string{"error": "weather provider rate limited"})
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
 
 func main() {
+	slog.SetDefault(newLogger(os.Getenv("LOG_LEVEL")))
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	collectorURL := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if collectorURL == "" {
-		collectorURL = "otel-collector:4317"
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	provider, err := newWeatherProvider(cfg)
+	if err != nil {
+		slog.Error("Failed to configure weather provider", "error", err)
+		os.Exit(1)
+	}
+	// The providers also check their key on every request, but a missing key
+	// should show up at startup rather than as 500s once traffic arrives.
+	if key := missingAPIKey(provider); key != "" && !cfg.DryRun {
+		if cfg.FailOnMissingKey {
+			slog.Error("Weather API key is not set", "variable", key, "provider", provider.Name())
+			os.Exit(1)
+		}
+		slog.Warn("Weather API key is not set, temperature requests will fail until it is configured",
+			"variable", key, "provider", provider.Name())
+	}
+	weatherProvider = provider
+	dryRun = cfg.DryRun
+	forceTraceEnabled = os.Getenv("DEBUG_FORCE_TRACE") == "true"
+	detailedHTTPTrace = os.Getenv("DETAILED_HTTP_TRACE") == "true"
+	maxUpstreamResponseBytes = int64(cfg.UpstreamMaxResponseBytes)
+	if cfg.ChaosEnabled {
+		chaos = newChaosInjector(cfg.ChaosFailureRate, cfg.ChaosSeed)
+		slog.Warn("Chaos mode: failing a fraction of requests on purpose",
+			"failure_rate", cfg.ChaosFailureRate, "seed", cfg.ChaosSeed)
+	}
+	if dryRun {
+		slog.Warn("Dry-run mode: ViaCEP and the weather provider are not called, responses are synthetic")
+	}
+	userAgent = cfg.UserAgent
+	upstreamClient = newUpstreamClient(cfg.UpstreamMaxConnsPerHost, cfg.UpstreamMaxRedirects, cfg.UpstreamRedirectAllowedHosts)
+	weatherMaxAttempts = cfg.WeatherMaxAttempts
+	upstreamTimeout = cfg.UpstreamTimeout
+	weatherBreaker = newCircuitBreaker(
+		getEnvInt("WEATHER_BREAKER_FAILURE_THRESHOLD", 5),
+		getEnvDuration("WEATHER_BREAKER_COOLDOWN", 30*time.Second),
+	)
+
+	verifyUF = os.Getenv("VERIFY_UF") == "true"
+	includeProviders = os.Getenv("INCLUDE_PROVIDERS") == "true"
+	ufMismatchAction = getEnv("UF_MISMATCH_ACTION", "warn")
+	if ufMismatchAction != "warn" && ufMismatchAction != "reject" {
+		slog.Error("Invalid UF_MISMATCH_ACTION, expected warn or reject", "value", ufMismatchAction)
+		os.Exit(1)
+	}
+
+	if cfg.TempCacheTTL > 0 {
+		temperatureResponses = newTemperatureCache(cfg.TempCacheTTL, cfg.CEPCacheMax)
 	}
 
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "servico-b"
+	cityLabels = newCityLabelLimiter(getEnvInt("CITY_LABEL_MAX_CARDINALITY", 100))
+
+	endpoints, scheme, err := parseCollectorEndpoints(cfg.CollectorEndpoints)
+	if err != nil {
+		slog.Error("Invalid OTEL_EXPORTER_OTLP_ENDPOINT", "error", err)
+		os.Exit(1)
 	}
+	cfg.CollectorEndpoints = endpoints
 
-	shutdown, err := initProvider(serviceName, collectorURL)
+	creds, err := collectorCredentials(scheme)
 	if err != nil {
-		log.Printf("Warning: Failed to initialize OTEL provider: %v. Continuing without tracing.", err)
+		slog.Error("Invalid OTEL collector TLS configuration", "error", err)
+		os.Exit(1)
+	}
+
+	shutdown, err := initProvider(ctx, cfg, creds)
+	if errors.Is(err, context.Canceled) {
+		slog.Info("Startup interrupted while connecting to the OTEL collector")
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to initialize OTEL provider, continuing without tracing", "error", err)
 		shutdown = func(context.Context) error { return nil }
 	}
+	svc := newApp(ViaCEPResolver{BaseURL: cfg.ViaCEPBaseURL})
+	if os.Getenv("WARMUP_TRACES") == "true" {
+		go svc.warmupTraces(ctx)
+	}
+
 	defer func() {
-		if err := shutdown(ctx); err != nil {
-			log.Printf("Warning: Failed to shutdown TracerProvider: %v", err)
+		// ctx is already cancelled once a signal arrives, so flushing the
+		// buffered spans gets its own deadline.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.TraceShutdownTimeout)
+		defer cancel()
+		if err := shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shutdown telemetry providers", "error", err)
 		}
 	}()
 
+	if err := initMetrics(); err != nil {
+		slog.Error("Failed to initialize metrics", "error", err)
+		os.Exit(1)
+	}
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	router.Use(echoRequestID)
 	router.Use(middleware.RealIP)
-	router.Use(middleware.Logger)
+	router.Use(accessLog(parseLogLevel(getEnv("ACCESS_LOG_LEVEL", "info"))))
 	router.Use(middleware.Recoverer)
-	router.Post("/temperature", handleTemperature)
-
-	port := os.Getenv("HTTP_PORT")
-	if port == "" {
-		port = ":8081"
+	router.Use(compressResponses(cfg.CompressMinSize))
+	router.Use(serverTracing(cfg.ServiceName))
+	router.Use(metricsMiddleware)
+	router.Handle("/metrics", metricsHandler())
+	router.Get("/version", svc.handleVersion)
+	if os.Getenv("DEBUG_ENDPOINTS") == "true" {
+		router.Get("/debug/config", svc.debugConfigHandler(cfg, creds))
 	}
+	router.With(svc.requireJSON, serverTimingMiddleware).Post("/temperature", svc.handleTemperature)
+	router.With(svc.requireJSON, serverTimingMiddleware).Post("/temperature/city", svc.handleCityTemperature)
+	router.NotFound(svc.handleNotFound)
+	router.MethodNotAllowed(svc.handleMethodNotAllowed)
 
 	go func() {
-		log.Printf("Serviço B iniciado na porta %s", port)
-		if err := http.ListenAndServe(port, router); err != nil {
-			log.Fatal(err)
+		slog.Info("Serviço B iniciado", "addr", cfg.HTTPAddr)
+		if err := http.ListenAndServe(cfg.HTTPAddr, router); err != nil {
+			slog.Error("HTTP server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	select {
 	case <-sigCh:
-		log.Println("Shutting down gracefully, CTRL+C pressed...")
+		slog.Info("Shutting down gracefully, CTRL+C pressed...")
 	case <-ctx.Done():
-		log.Println("Shutting down due to other reason...")
+		slog.Info("Shutting down due to other reason...")
 	}
 }
-
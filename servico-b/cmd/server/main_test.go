@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// fakeViaCEP serves the addresses in known by CEP and answers {"erro": true}
+// for any other CEP, as ViaCEP does.
+func fakeViaCEP(t *testing.T, known map[string]ViaCEPResponse) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cep := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/json/")
+		address, ok := known[cep]
+		if !ok {
+			address = ViaCEPResponse{Erro: true}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(address)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeWeatherAPI answers every current.json request with status and body.
+func fakeWeatherAPI(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/current.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestRouter wires the handlers the way main does, from cfg, without the
+// collector and the server-level middlewares. The response cache is left
+// off so every request reaches the fakes.
+func newTestRouter(t *testing.T, cfg Config) http.Handler {
+	t.Helper()
+
+	provider, err := newWeatherProvider(cfg)
+	if err != nil {
+		t.Fatalf("newWeatherProvider: %v", err)
+	}
+	weatherProvider = provider
+	dryRun = false
+	upstreamClient = newUpstreamClient(cfg.UpstreamMaxConnsPerHost, cfg.UpstreamMaxRedirects, cfg.UpstreamRedirectAllowedHosts)
+	upstreamTimeout = cfg.UpstreamTimeout
+	weatherMaxAttempts = 1
+	weatherBreaker = newCircuitBreaker(5, 30*time.Second)
+	cityLabels = newCityLabelLimiter(100)
+	temperatureResponses = nil
+	if err := initMetrics(); err != nil {
+		t.Fatalf("initMetrics: %v", err)
+	}
+
+	svc := newApp(ViaCEPResolver{BaseURL: cfg.ViaCEPBaseURL})
+	router := chi.NewRouter()
+	router.With(svc.requireJSON).Post("/temperature", svc.handleTemperature)
+	router.With(svc.requireJSON).Post("/temperature/city", svc.handleCityTemperature)
+	return router
+}
+
+// recordSpans installs a tracer provider as the global one for the duration
+// of the test and returns the recorder of its spans. Call it before
+// newTestRouter, so the app's tracer comes from this provider.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// testConfig points the ViaCEP and WeatherAPI base URLs at the fakes.
+func testConfig(viaCEP, weatherAPI *httptest.Server) Config {
+	cfg := defaultConfig()
+	cfg.ViaCEPBaseURL = viaCEP.URL
+	cfg.WeatherAPIBaseURL = weatherAPI.URL
+	cfg.WeatherAPIKey = "test-key"
+	return cfg
+}
+
+func postTemperature(t *testing.T, router http.Handler, cep string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/temperature", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CEP", cep)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+const paulistaWeather = `{"location":{"name":"Sao Paulo","region":"Sao Paulo"},"current":{"temp_c":28.5}}`
+
+func TestHandleTemperature(t *testing.T) {
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"01310100": {Cep: "01310-100", Logradouro: "Avenida Paulista", Bairro: "Bela Vista", Localidade: "São Paulo", UF: "SP"},
+	})
+	weatherAPI := fakeWeatherAPI(t, http.StatusOK, paulistaWeather)
+	router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+
+	rec := postTemperature(t, router, "01310-100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got TemperatureResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := TemperatureResponse{City: "São Paulo", TempC: 28.5, TempF: 83.3, TempK: 301.7}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("response = %+v, want %+v", got, want)
+	}
+}
+
+func TestWeatherLookupStartsAfterCEPLookup(t *testing.T) {
+	recorder := recordSpans(t)
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"01310100": {Cep: "01310-100", Localidade: "São Paulo", UF: "SP"},
+	})
+	weatherAPI := fakeWeatherAPI(t, http.StatusOK, paulistaWeather)
+	router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+
+	if rec := postTemperature(t, router, "01310100"); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	searchCEP, getTemperature := spans["servico-b.searchCEP"], spans["servico-b.getTemperature"]
+	if searchCEP == nil || getTemperature == nil {
+		t.Fatalf("missing spans, recorded %v", spans)
+	}
+	if searchCEP.EndTime().After(getTemperature.StartTime()) {
+		t.Errorf("servico-b.getTemperature started at %v, before servico-b.searchCEP ended at %v",
+			getTemperature.StartTime(), searchCEP.EndTime())
+	}
+}
+
+func TestProvidersListWeatherFallback(t *testing.T) {
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"01310100": {Cep: "01310-100", Localidade: "São Paulo", UF: "SP"},
+	})
+	// The weather provider is unavailable on the first call only.
+	var calls atomic.Int32
+	weatherAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(paulistaWeather))
+	}))
+	t.Cleanup(weatherAPI.Close)
+	router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+	weatherMaxAttempts = 2
+	previous := includeProviders
+	includeProviders = true
+	t.Cleanup(func() { includeProviders = previous })
+
+	rec := postTemperature(t, router, "01310100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got TemperatureResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []ProviderOutcome{
+		{Kind: "cep", Name: "viacep", Outcome: "ok"},
+		{Kind: "weather", Name: "weatherapi", Attempt: 1, Outcome: "error"},
+		{Kind: "weather", Name: "weatherapi", Attempt: 2, Outcome: "ok"},
+	}
+	if !reflect.DeepEqual(got.Providers, want) {
+		t.Errorf("providers = %+v, want %+v", got.Providers, want)
+	}
+}
+
+func TestHandleTemperatureErrors(t *testing.T) {
+	viaCEP := fakeViaCEP(t, nil)
+	weatherAPI := fakeWeatherAPI(t, http.StatusOK, paulistaWeather)
+	router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+
+	tests := []struct {
+		name       string
+		cep        string
+		wantStatus int
+		wantError  string
+	}{
+		{"invalid zipcode", "123", http.StatusUnprocessableEntity, "invalid zipcode"},
+		{"zipcode not found", "99999999", http.StatusNotFound, "can not find zipcode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postTemperature(t, router, tt.cep)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTemperatureConversion(t *testing.T) {
+	const tempC = 28.5
+	tests := []struct {
+		name      string
+		got       float64
+		precision int
+		want      float64
+	}{
+		{"celsius", roundTo(tempC, 1), 1, 28.5},
+		{"fahrenheit", celsiusToFahrenheit(tempC), 1, 83.3},
+		{"kelvin", celsiusToKelvin(tempC), 2, 301.65},
+		{"kelvin at the default precision", celsiusToKelvin(tempC), defaultPrecision, 301.7},
+		{"kelvin without decimals", celsiusToKelvin(tempC), 0, 302},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundTo(tt.got, tt.precision); got != tt.want {
+				t.Errorf("roundTo(%v, %d) = %v, want %v", tt.got, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
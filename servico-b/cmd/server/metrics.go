@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const upstreamDurationName = "upstream.duration"
+
+// defaultUpstreamBuckets are the histogram boundaries, in milliseconds, used
+// for upstream latency. They are tuned for APIs that answer in under a second.
+var defaultUpstreamBuckets = []float64{5, 10, 25, 50, 75, 100, 150, 250, 500, 750, 1000, 2500, 5000}
+
+var (
+	requestCount    metric.Int64Counter
+	requestDuration metric.Float64Histogram
+
+	// upstreamDuration records the latency of each call to ViaCEP and to
+	// the weather provider, labeled by upstream.
+	upstreamDuration metric.Float64Histogram
+
+	// cacheEvictions counts entries dropped from the temperature cache,
+	// labeled by reason (capacity or expired).
+	cacheEvictions metric.Int64Counter
+
+	// weatherRateLimited counts 429 answers from the weather provider,
+	// labeled by provider, to show quota pressure.
+	weatherRateLimited metric.Int64Counter
+)
+
+// parseBuckets parses a comma-separated list of ascending histogram
+// boundaries, such as the value of UPSTREAM_HISTOGRAM_BUCKETS.
+func parseBuckets(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	buckets := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket boundary %q: %w", part, err)
+		}
+		buckets = append(buckets, bound)
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return nil, fmt.Errorf("bucket boundaries must be in ascending order: %s", value)
+	}
+	return buckets, nil
+}
+
+// upstreamHistogramView applies the explicit bucket boundaries to the
+// upstream latency histogram.
+func upstreamHistogramView(buckets []float64) sdkmetric.View {
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: upstreamDurationName},
+		sdkmetric.Stream{
+			Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: buckets},
+		},
+	)
+}
+
+// initMetrics creates the instruments on the global MeterProvider.
+func initMetrics() error {
+	meter := otel.Meter(instrumentationScope, metric.WithInstrumentationVersion(version))
+
+	var err error
+	requestCount, err = meter.Int64Counter("http.server.request.count",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of HTTP requests handled"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request counter: %w", err)
+	}
+
+	requestDuration, err = meter.Float64Histogram("http.server.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Duration of HTTP requests"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request duration histogram: %w", err)
+	}
+
+	upstreamDuration, err = meter.Float64Histogram(upstreamDurationName,
+		metric.WithUnit("ms"),
+		metric.WithDescription("Latency of requests to upstream APIs"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s histogram: %w", upstreamDurationName, err)
+	}
+
+	cacheEvictions, err = meter.Int64Counter("cache.evictions",
+		metric.WithUnit("{entry}"),
+		metric.WithDescription("Number of entries evicted from the temperature cache"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create cache eviction counter: %w", err)
+	}
+
+	weatherRateLimited, err = meter.Int64Counter("weather.rate_limited",
+		metric.WithUnit("{response}"),
+		metric.WithDescription("Number of 429 responses from the weather provider"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create rate limit counter: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge("weather.circuit_breaker.state",
+		metric.WithDescription("Weather provider circuit breaker state (0 closed, 1 open, 2 half-open)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(weatherBreaker.State()))
+			return nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create circuit breaker gauge: %w", err)
+	}
+	return nil
+}
+
+func recordUpstreamDuration(ctx context.Context, upstream string, d time.Duration, attrs ...attribute.KeyValue) {
+	attrs = append(attrs, attribute.String("upstream", upstream))
+	upstreamDuration.Record(ctx, durationMillis(d), metric.WithAttributes(attrs...))
+}
+
+// recordRateLimited counts a 429 answer from the weather provider.
+func recordRateLimited(ctx context.Context, provider string) {
+	if weatherRateLimited != nil {
+		weatherRateLimited.Add(ctx, 1, metric.WithAttributes(attribute.String("weather.provider", provider)))
+	}
+}
+
+// durationMillis converts d to fractional milliseconds.
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// metricsHandler serves /metrics. Exemplars are only part of the OpenMetrics
+// format, which is served when the scraper asks for it.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
+}
+
+// metricsMiddleware counts requests and records their latency labeled by
+// route, method and status code. It runs inside serverTracing, so the
+// request context carries the server span and the latency observation
+// gets its trace ID as an exemplar.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := metric.WithAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPStatusCode(status),
+		)
+		requestCount.Add(r.Context(), 1, attrs)
+		requestDuration.Record(r.Context(), durationMillis(time.Since(startTime)), attrs)
+	})
+}
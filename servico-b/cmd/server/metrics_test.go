@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestUpstreamHistogramBuckets(t *testing.T) {
+	buckets, err := parseBuckets("10, 50,100,1000")
+	if err != nil {
+		t.Fatalf("parseBuckets: %v", err)
+	}
+	want := []float64{10, 50, 100, 1000}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Fatalf("parseBuckets = %v, want %v", buckets, want)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(upstreamHistogramView(buckets)),
+	)
+	histogram, err := provider.Meter(instrumentationScope).Float64Histogram(upstreamDurationName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram.Record(context.Background(), 42)
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	data, ok := metrics.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("%s is a %T, want a float64 histogram", upstreamDurationName, metrics.ScopeMetrics[0].Metrics[0].Data)
+	}
+	if got := data.DataPoints[0].Bounds; !reflect.DeepEqual(got, want) {
+		t.Errorf("bucket boundaries = %v, want %v", got, want)
+	}
+}
+
+func TestParseBucketsRejectsUnsortedBoundaries(t *testing.T) {
+	if _, err := parseBuckets("100,50"); err == nil {
+		t.Error("parseBuckets accepted descending boundaries")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// propagators maps the names accepted in OTEL_PROPAGATORS to their
+// implementations. "b3" injects the single b3 header and "b3multi" the
+// X-B3-* headers; both extract either form.
+var propagators = map[string]propagation.TextMapPropagator{
+	"tracecontext": propagation.TraceContext{},
+	"baggage":      propagation.Baggage{},
+	"b3":           b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)),
+	"b3multi":      b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
+}
+
+// newPropagator combines the named propagators, in order. Names are checked
+// by loadConfig.
+func newPropagator(names []string) propagation.TextMapPropagator {
+	selected := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		selected = append(selected, propagators[name])
+	}
+	return propagation.NewCompositeTextMapPropagator(selected...)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ProviderOutcome describes one call made to an upstream provider while
+// serving a request.
+type ProviderOutcome struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Attempt int    `json:"attempt,omitempty"`
+	Outcome string `json:"outcome"`
+}
+
+// providerLog collects the provider calls of a single request. It is only
+// attached to the context when INCLUDE_PROVIDERS is enabled.
+type providerLog struct {
+	mu       sync.Mutex
+	outcomes []ProviderOutcome
+}
+
+type providerLogKey struct{}
+
+func withProviderLog(ctx context.Context) (context.Context, *providerLog) {
+	log := &providerLog{}
+	return context.WithValue(ctx, providerLogKey{}, log), log
+}
+
+// recordProvider appends an outcome to the request's provider log, if any.
+func recordProvider(ctx context.Context, outcome ProviderOutcome) {
+	log, ok := ctx.Value(providerLogKey{}).(*providerLog)
+	if !ok {
+		return
+	}
+	log.mu.Lock()
+	log.outcomes = append(log.outcomes, outcome)
+	log.mu.Unlock()
+}
+
+func (l *providerLog) Outcomes() []ProviderOutcome {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]ProviderOutcome(nil), l.outcomes...)
+}
+
+// providerOutcomeName maps an upstream error to the outcome reported to
+// clients.
+func providerOutcomeName(err error) string {
+	var timeoutErr *upstreamTimeoutError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrZipcodeNotFound), errors.Is(err, ErrLocalityUnavailable), errors.Is(err, ErrLocationNotFound):
+		return "not_found"
+	case errors.Is(err, ErrInvalidZipcode):
+		return "invalid"
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "strings"
+
+// ufNames maps each Brazilian UF to its state name as reported by weather
+// providers, normalized by normalizeRegion.
+var ufNames = map[string]string{
+	"AC": "acre",
+	"AL": "alagoas",
+	"AP": "amapa",
+	"AM": "amazonas",
+	"BA": "bahia",
+	"CE": "ceara",
+	"DF": "distrito federal",
+	"ES": "espirito santo",
+	"GO": "goias",
+	"MA": "maranhao",
+	"MT": "mato grosso",
+	"MS": "mato grosso do sul",
+	"MG": "minas gerais",
+	"PA": "para",
+	"PB": "paraiba",
+	"PR": "parana",
+	"PE": "pernambuco",
+	"PI": "piaui",
+	"RJ": "rio de janeiro",
+	"RN": "rio grande do norte",
+	"RS": "rio grande do sul",
+	"RO": "rondonia",
+	"RR": "roraima",
+	"SC": "santa catarina",
+	"SP": "sao paulo",
+	"SE": "sergipe",
+	"TO": "tocantins",
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "ê", "e",
+	"í", "i",
+	"ó", "o", "ô", "o", "õ", "o",
+	"ú", "u",
+	"ç", "c",
+)
+
+func normalizeRegion(region string) string {
+	return accentReplacer.Replace(strings.ToLower(strings.TrimSpace(region)))
+}
+
+// regionMatchesUF reports whether a provider region corresponds to the UF
+// returned by ViaCEP. Unknown UFs are treated as a match so that the check
+// never rejects a location it cannot judge.
+func regionMatchesUF(region, uf string) bool {
+	name, ok := ufNames[strings.ToUpper(uf)]
+	if !ok {
+		return true
+	}
+	normalized := normalizeRegion(region)
+	return normalized == name || normalized == strings.ToLower(uf)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRegionMatchesUF(t *testing.T) {
+	tests := []struct {
+		region string
+		uf     string
+		want   bool
+	}{
+		{"Sao Paulo", "SP", true},
+		{"São Paulo", "sp", true},
+		{" Ceará ", "CE", true},
+		{"RJ", "RJ", true},
+		{"Sao Paulo", "RJ", false},
+		{"Mato Grosso", "MS", false},
+		{"Anywhere", "XX", true},
+	}
+	for _, tt := range tests {
+		if got := regionMatchesUF(tt.region, tt.uf); got != tt.want {
+			t.Errorf("regionMatchesUF(%q, %q) = %v, want %v", tt.region, tt.uf, got, tt.want)
+		}
+	}
+}
+
+func TestUFMismatch(t *testing.T) {
+	// ViaCEP places the CEP in RJ while the weather provider answers with a
+	// region in SP.
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"20040002": {Cep: "20040-002", Localidade: "São Paulo", UF: "RJ"},
+	})
+	weatherAPI := fakeWeatherAPI(t, http.StatusOK, paulistaWeather)
+	previousVerify, previousAction := verifyUF, ufMismatchAction
+	t.Cleanup(func() { verifyUF, ufMismatchAction = previousVerify, previousAction })
+
+	tests := []struct {
+		action     string
+		wantStatus int
+	}{
+		{"warn", http.StatusOK},
+		{"reject", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			t.Cleanup(func() { otel.SetTracerProvider(previous) })
+			verifyUF, ufMismatchAction = true, tt.action
+			router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+
+			rec := postTemperature(t, router, "20040002")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.action == "reject" {
+				var body map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if body["error"] != "location_mismatch" {
+					t.Errorf("error = %q, want %q", body["error"], "location_mismatch")
+				}
+			}
+
+			mismatches := 0
+			for _, span := range recorder.Ended() {
+				for _, event := range span.Events() {
+					if event.Name == "location.mismatch" {
+						mismatches++
+					}
+				}
+			}
+			if mismatches != 1 {
+				t.Errorf("recorded %d location.mismatch events, want 1", mismatches)
+			}
+		})
+	}
+}
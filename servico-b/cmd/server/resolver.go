@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Address is what a CEP resolves to: the locality used to look the weather
+// up, plus the details returned for ?verbose=true.
+type Address struct {
+	CEP          string
+	Street       string
+	Neighborhood string
+	City         string
+	State        string
+}
+
+// CEPResolver looks a CEP up. Resolve returns ErrInvalidZipcode,
+// ErrZipcodeNotFound or ErrLocalityUnavailable when the CEP cannot be used
+// to look the weather up.
+type CEPResolver interface {
+	Resolve(ctx context.Context, cep string) (Address, error)
+}
+
+const defaultViaCEPBaseURL = "https://viacep.com.br/ws"
+
+type ViaCEPResponse struct {
+	Cep         string `json:"cep"`
+	Logradouro  string `json:"logradouro"`
+	Complemento string `json:"complemento"`
+	Bairro      string `json:"bairro"`
+	Localidade  string `json:"localidade"`
+	UF          string `json:"uf"`
+	Erro        bool   `json:"erro"`
+}
+
+// ViaCEPResolver queries https://viacep.com.br/. BaseURL is the API root,
+// which can point at a mirror or a test double.
+type ViaCEPResolver struct {
+	BaseURL string
+}
+
+func (r ViaCEPResolver) Resolve(ctx context.Context, cep string) (Address, error) {
+	span := trace.SpanFromContext(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/%s/json/", r.BaseURL, cep)
+
+	req, err := newUpstreamRequest(ctx, url)
+	if err != nil {
+		return Address{}, err
+	}
+
+	startTime := time.Now()
+	resp, err := doUpstream(req)
+	duration := time.Since(startTime)
+	span.SetAttributes(attribute.Float64("http.client.duration_ms", durationMillis(duration)))
+	recordUpstreamDuration(ctx, "viacep", duration)
+	addServerTiming(ctx, "viacep", duration)
+
+	if err != nil {
+		return Address{}, checkTimeout(ctx, req, duration, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return Address{}, ErrInvalidZipcode
+	}
+
+	body, err := readUpstreamBody(ctx, resp.Body)
+	if err != nil {
+		return Address{}, checkTimeout(ctx, req, time.Since(startTime), err)
+	}
+
+	var viaCEPResp ViaCEPResponse
+	if err := json.Unmarshal(body, &viaCEPResp); err != nil {
+		return Address{}, err
+	}
+
+	if viaCEPResp.Erro {
+		return Address{}, ErrZipcodeNotFound
+	}
+
+	// Some special CEPs come back without a locality; querying the weather
+	// provider with an empty city would only fail further down.
+	if strings.TrimSpace(viaCEPResp.Localidade) == "" {
+		span.SetAttributes(
+			attribute.String("cep", cep),
+			attribute.Bool("viacep.localidade_empty", true),
+		)
+		return Address{}, ErrLocalityUnavailable
+	}
+
+	slog.DebugContext(ctx, "CEP search finished", "duration", duration)
+
+	return viaCEPResp.address(), nil
+}
+
+func (v ViaCEPResponse) address() Address {
+	return Address{
+		CEP:          v.Cep,
+		Street:       v.Logradouro,
+		Neighborhood: v.Bairro,
+		City:         v.Localidade,
+		State:        v.UF,
+	}
+}
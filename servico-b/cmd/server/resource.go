@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// newResource describes this service instance. CLOUD_REGION, when set, is
+// recorded as cloud.region so traces can be filtered by deployment region;
+// build.dirty flags spans coming from non-reproducible dev builds.
+// Attributes injected by the platform through OTEL_RESOURCE_ATTRIBUTES (such
+// as service.namespace or k8s.pod.name) are kept, but the ones set here win
+// on conflict.
+func newResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		attribute.Bool("build.dirty", buildDirty()),
+	}
+	if region := os.Getenv("CLOUD_REGION"); region != "" {
+		attrs = append(attrs, semconv.CloudRegion(region))
+	}
+
+	// Later options take precedence when resource.New merges them.
+	return resource.New(ctx, resource.WithFromEnv(), resource.WithAttributes(attrs...))
+}
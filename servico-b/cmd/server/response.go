@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// writeJSON writes v as a JSON response with the given status code. The
+// encoding runs in its own "encode.response" span with the body size.
+func (a *app) writeJSON(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, span := a.tracer.Start(ctx, "encode.response")
+	counter := &countingWriter{w: w}
+	err := json.NewEncoder(counter).Encode(v)
+	span.SetAttributes(attribute.Int64("encode.size_bytes", counter.n))
+	span.End()
+	if err != nil {
+		recordWriteFailure(ctx, err)
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// recordWriteFailure notes that the response could not be written. This
+// almost always means the client disconnected, so it is logged at debug
+// level and recorded on the active span rather than treated as an error.
+func recordWriteFailure(ctx context.Context, err error) {
+	slog.DebugContext(ctx, "Failed to write response", "error", err)
+	trace.SpanFromContext(ctx).AddEvent("response.write_failed",
+		trace.WithAttributes(attribute.String("error", err.Error())),
+	)
+}
+
+func (a *app) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(r.Context(), w, http.StatusNotFound, map[string]string{"error": "not found"})
+}
+
+func (a *app) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(r.Context(), w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+}
+
+// requireJSON rejects requests whose Content-Type is not application/json
+// with 415. Parameters such as charset are allowed.
+func (a *app) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			a.writeJSON(r.Context(), w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
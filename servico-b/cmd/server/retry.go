@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// weatherRetryBaseDelay is the backoff before the second attempt; it
+	// doubles on every further attempt.
+	weatherRetryBaseDelay = 200 * time.Millisecond
+	// maxRetryAfter caps how long a Retry-After header can hold a request.
+	maxRetryAfter = 5 * time.Second
+)
+
+// ErrRateLimited is matched by an upstreamStatusError for a 429 answer, when
+// the weather provider throttles us for exceeding the plan's quota.
+var ErrRateLimited = errors.New("weather provider rate limited")
+
+// upstreamStatusError is returned by weather providers when the API answers
+// with a non-200 status.
+type upstreamStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	// RetryAfterHeader is the Retry-After header as sent by the upstream,
+	// so it can be echoed to our own clients.
+	RetryAfterHeader string
+	err              error
+}
+
+func (e *upstreamStatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *upstreamStatusError) Unwrap() []error {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return []error{e.err, ErrRateLimited}
+	}
+	return []error{e.err}
+}
+
+func newUpstreamStatusError(resp *http.Response, err error) *upstreamStatusError {
+	return &upstreamStatusError{
+		StatusCode:       resp.StatusCode,
+		RetryAfter:       parseRetryAfter(resp.Header.Get("Retry-After")),
+		RetryAfterHeader: resp.Header.Get("Retry-After"),
+		err:              err,
+	}
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number of
+// seconds or an HTTP date. It returns zero when the header is absent or
+// malformed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryable reports whether a failed weather request is worth repeating:
+// network errors, 429 and 5xx are transient, any other 4xx is permanent.
+func retryable(err error) bool {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryDelay is how long to wait before attempt+1, honouring Retry-After
+// when the upstream sent one.
+func retryDelay(attempt int, err error) time.Duration {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return min(statusErr.RetryAfter, maxRetryAfter)
+	}
+	return weatherRetryBaseDelay << (attempt - 1)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverTimings accumulates the upstream durations of a single request,
+// reported to the client in the Server-Timing header.
+type serverTimings struct {
+	mu      sync.Mutex
+	names   []string
+	metrics map[string]time.Duration
+}
+
+type serverTimingsKey struct{}
+
+// addServerTiming adds d to the metric called name on the request's timings,
+// if any. Repeated calls, such as weather retries, are summed.
+func addServerTiming(ctx context.Context, name string, d time.Duration) {
+	timings, ok := ctx.Value(serverTimingsKey{}).(*serverTimings)
+	if !ok {
+		return
+	}
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	if _, seen := timings.metrics[name]; !seen {
+		timings.names = append(timings.names, name)
+	}
+	timings.metrics[name] += d
+}
+
+// Header formats the timings per the Server-Timing spec, for example
+// "viacep;dur=42.1, weather;dur=130.7".
+func (t *serverTimings) Header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]string, 0, len(t.names))
+	for _, name := range t.names {
+		entries = append(entries, fmt.Sprintf("%s;dur=%.1f", name, durationMillis(t.metrics[name])))
+	}
+	return strings.Join(entries, ", ")
+}
+
+// serverTimingMiddleware collects the upstream durations of each request and
+// sets the Server-Timing header just before the response headers are sent.
+func serverTimingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timings := &serverTimings{metrics: map[string]time.Duration{}}
+		ctx := context.WithValue(r.Context(), serverTimingsKey{}, timings)
+		next.ServeHTTP(&serverTimingWriter{ResponseWriter: w, timings: timings}, r.WithContext(ctx))
+	})
+}
+
+type serverTimingWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	wroteHeader bool
+}
+
+func (w *serverTimingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if header := w.timings.Header(); header != "" {
+			w.ResponseWriter.Header().Set("Server-Timing", header)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *serverTimingWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// temperatureCache keeps recent TemperatureResponses by normalized CEP. The
+// weather of a city changes slowly, so a short ttl spares both upstreams on
+// repeated lookups. Entries are stored with the verbose fields filled in and
+// the temperatures unrounded, so a hit can serve any kind of request. The
+// cache holds at most maxEntries CEPs, evicting the least recently used one
+// when full.
+type temperatureCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	// recency has the most recently used entry at the front.
+	recency *list.List
+}
+
+type cachedTemperature struct {
+	cep      string
+	response TemperatureResponse
+	expires  time.Time
+}
+
+func newTemperatureCache(ttl time.Duration, maxEntries int) *temperatureCache {
+	return &temperatureCache{
+		ttl:        ttl,
+		maxEntries: max(maxEntries, 1),
+		entries:    make(map[string]*list.Element),
+		recency:    list.New(),
+	}
+}
+
+func (c *temperatureCache) Get(ctx context.Context, cep string) (TemperatureResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[cep]
+	if !ok {
+		return TemperatureResponse{}, false
+	}
+	entry := elem.Value.(*cachedTemperature)
+	if time.Now().After(entry.expires) {
+		c.evict(ctx, elem, "expired")
+		return TemperatureResponse{}, false
+	}
+	c.recency.MoveToFront(elem)
+	return entry.response, true
+}
+
+func (c *temperatureCache) Put(ctx context.Context, cep string, response TemperatureResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	response.Providers = nil
+	entry := &cachedTemperature{cep: cep, response: response, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[cep]; ok {
+		elem.Value = entry
+		c.recency.MoveToFront(elem)
+		return
+	}
+	for c.recency.Len() >= c.maxEntries {
+		c.evict(ctx, c.recency.Back(), "capacity")
+	}
+	c.entries[cep] = c.recency.PushFront(entry)
+}
+
+func (c *temperatureCache) evict(ctx context.Context, elem *list.Element, reason string) {
+	c.recency.Remove(elem)
+	delete(c.entries, elem.Value.(*cachedTemperature).cep)
+	if cacheEvictions != nil {
+		cacheEvictions.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("cache", "temperature"),
+			attribute.String("reason", reason),
+		))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// upstreamTimeout bounds each call to ViaCEP and each weather attempt, so a
+// slow upstream is reported as a timeout rather than holding the request
+// until servico-a gives up.
+var upstreamTimeout = defaultUpstreamTimeout
+
+const defaultUpstreamTimeout = 3 * time.Second
+
+// upstreamTimeoutError is returned when an upstream call did not finish
+// before its context deadline. handleTemperature maps it to 504.
+type upstreamTimeoutError struct {
+	URL     string
+	Elapsed time.Duration
+	err     error
+}
+
+func (e *upstreamTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s: %v", e.URL, e.Elapsed, e.err)
+}
+
+func (e *upstreamTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// checkTimeout turns err into an upstreamTimeoutError when req failed because
+// its deadline passed, recording a "timeout" event on the span in ctx. Other
+// errors are returned unchanged.
+func checkTimeout(ctx context.Context, req *http.Request, elapsed time.Duration, err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	target := redactedURL(req.URL)
+	trace.SpanFromContext(ctx).AddEvent("timeout", trace.WithAttributes(
+		attribute.String("upstream.url", target),
+		attribute.Float64("timeout.elapsed_ms", durationMillis(elapsed)),
+	))
+	return &upstreamTimeoutError{URL: target, Elapsed: elapsed, err: err}
+}
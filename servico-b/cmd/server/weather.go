@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WeatherProvider fetches the current temperature, in Celsius, for a city.
+type WeatherProvider interface {
+	Name() string
+	Temperature(ctx context.Context, city string) (float64, error)
+}
+
+// RegionalWeatherProvider is implemented by providers that also report the
+// region (state) of the location the city name was resolved to.
+type RegionalWeatherProvider interface {
+	WeatherProvider
+	TemperatureWithRegion(ctx context.Context, city string) (tempC float64, region string, err error)
+}
+
+const (
+	defaultWeatherAPIBaseURL     = "http://api.weatherapi.com/v1"
+	defaultOpenWeatherMapBaseURL = "https://api.openweathermap.org/data/2.5"
+)
+
+// newWeatherProvider returns the provider selected by cfg.WeatherProvider,
+// configured with its API root and key.
+func newWeatherProvider(cfg Config) (WeatherProvider, error) {
+	switch cfg.WeatherProvider {
+	case "", "weatherapi":
+		apiKey, err := weatherAPIKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return weatherAPIProvider{baseURL: cfg.WeatherAPIBaseURL, apiKey: apiKey}, nil
+	case "openweathermap":
+		return openWeatherMapProvider{baseURL: cfg.OpenWeatherMapBaseURL, apiKey: cfg.OpenWeatherMapAPIKey}, nil
+	case "mock":
+		return mockWeatherProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider %q", cfg.WeatherProvider)
+	}
+}
+
+type WeatherAPIResponse struct {
+	Location struct {
+		Name   string `json:"name"`
+		Region string `json:"region"`
+	} `json:"location"`
+	Current struct {
+		TempC float64 `json:"temp_c"`
+	} `json:"current"`
+	Error *WeatherAPIError `json:"error"`
+}
+
+// ErrLocationNotFound is returned when the weather provider cannot resolve
+// the city name to a location.
+var ErrLocationNotFound = errors.New("can not find location")
+
+// weatherAPINoLocationCode is the WeatherAPI error code for a query that did
+// not match any location.
+const weatherAPINoLocationCode = 1006
+
+// WeatherAPIError is the error object WeatherAPI reports in the body, which
+// it sometimes does with a 200 status.
+type WeatherAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *WeatherAPIError) Error() string {
+	return fmt.Sprintf("weather API error %d: %s", e.Code, e.Message)
+}
+
+func (e *WeatherAPIError) Unwrap() error {
+	if e.Code == weatherAPINoLocationCode {
+		return ErrLocationNotFound
+	}
+	return nil
+}
+
+// weatherAPIKey returns the WeatherAPI key, preferring the secret file named
+// by WEATHER_API_KEY_FILE over the WEATHER_API_KEY value.
+func weatherAPIKey(cfg Config) (string, error) {
+	if cfg.WeatherAPIKeyFile == "" {
+		return cfg.WeatherAPIKey, nil
+	}
+	data, err := os.ReadFile(cfg.WeatherAPIKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read WEATHER_API_KEY_FILE: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// missingAPIKey names the variable holding the key provider needs when that
+// key is empty, and returns "" otherwise.
+func missingAPIKey(provider WeatherProvider) string {
+	switch p := provider.(type) {
+	case weatherAPIProvider:
+		if p.apiKey == "" {
+			return "WEATHER_API_KEY"
+		}
+	case openWeatherMapProvider:
+		if p.apiKey == "" {
+			return "OPENWEATHERMAP_API_KEY"
+		}
+	}
+	return ""
+}
+
+// weatherAPIProvider queries https://www.weatherapi.com/.
+type weatherAPIProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+func (weatherAPIProvider) Name() string {
+	return "weatherapi"
+}
+
+func (p weatherAPIProvider) Temperature(ctx context.Context, city string) (float64, error) {
+	tempC, _, err := p.TemperatureWithRegion(ctx, city)
+	return tempC, err
+}
+
+func (p weatherAPIProvider) TemperatureWithRegion(ctx context.Context, city string) (float64, string, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if p.apiKey == "" {
+		return 0, "", fmt.Errorf("WEATHER_API_KEY not set")
+	}
+
+	// URL encode a cidade para evitar problemas com espaços e caracteres especiais
+	encodedCity := url.QueryEscape(city)
+	opts := weatherOptionsFrom(ctx)
+	aqi := "no"
+	if opts.aqi {
+		aqi = "yes"
+	}
+	url := fmt.Sprintf("%s/current.json?key=%s&q=%s&aqi=%s", p.baseURL, p.apiKey, encodedCity, aqi)
+	if opts.lang != "" {
+		url += "&lang=" + opts.lang
+	}
+
+	req, err := newUpstreamRequest(ctx, url)
+	if err != nil {
+		return 0, "", err
+	}
+
+	startTime := time.Now()
+	resp, err := doUpstream(req)
+	duration := time.Since(startTime)
+	span.SetAttributes(attribute.Float64("http.client.duration_ms", durationMillis(duration)))
+
+	if err != nil {
+		return 0, "", checkTimeout(ctx, req, duration, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readUpstreamBody(ctx, resp.Body)
+		slog.WarnContext(ctx, "Weather API error response", "status", resp.StatusCode, "body", string(body))
+		var errResp WeatherAPIResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+			return 0, "", newUpstreamStatusError(resp, fmt.Errorf("weather API returned status %d: %w", resp.StatusCode, errResp.Error))
+		}
+		return 0, "", newUpstreamStatusError(resp, fmt.Errorf("weather API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	body, err := readUpstreamBody(ctx, resp.Body)
+	if err != nil {
+		return 0, "", checkTimeout(ctx, req, time.Since(startTime), err)
+	}
+
+	var weatherResp WeatherAPIResponse
+	if err := json.Unmarshal(body, &weatherResp); err != nil {
+		return 0, "", err
+	}
+	if weatherResp.Error != nil {
+		slog.WarnContext(ctx, "Weather API error body", "code", weatherResp.Error.Code, "message", weatherResp.Error.Message)
+		return 0, "", weatherResp.Error
+	}
+
+	slog.DebugContext(ctx, "Temperature search finished", "duration", duration)
+
+	return weatherResp.Current.TempC, weatherResp.Location.Region, nil
+}
+
+type OpenWeatherMapResponse struct {
+	Name string `json:"name"`
+	Main struct {
+		Temp float64 `json:"temp"`
+	} `json:"main"`
+}
+
+// openWeatherMapProvider queries https://openweathermap.org/. Its API
+// reports temperatures in Kelvin unless asked otherwise.
+type openWeatherMapProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+func (openWeatherMapProvider) Name() string {
+	return "openweathermap"
+}
+
+func (p openWeatherMapProvider) Temperature(ctx context.Context, city string) (float64, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if p.apiKey == "" {
+		return 0, fmt.Errorf("OPENWEATHERMAP_API_KEY not set")
+	}
+
+	url := fmt.Sprintf("%s/weather?q=%s&appid=%s", p.baseURL, url.QueryEscape(city), p.apiKey)
+
+	req, err := newUpstreamRequest(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+
+	startTime := time.Now()
+	resp, err := doUpstream(req)
+	duration := time.Since(startTime)
+	span.SetAttributes(attribute.Float64("http.client.duration_ms", durationMillis(duration)))
+
+	if err != nil {
+		return 0, checkTimeout(ctx, req, duration, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readUpstreamBody(ctx, resp.Body)
+		slog.WarnContext(ctx, "OpenWeatherMap error response", "status", resp.StatusCode, "body", string(body))
+		// OpenWeatherMap reports an unknown city with a 404 rather than an
+		// error code in the body.
+		if resp.StatusCode == http.StatusNotFound {
+			return 0, newUpstreamStatusError(resp, fmt.Errorf("openweathermap returned status %d: %s: %w", resp.StatusCode, string(body), ErrLocationNotFound))
+		}
+		return 0, newUpstreamStatusError(resp, fmt.Errorf("openweathermap returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	body, err := readUpstreamBody(ctx, resp.Body)
+	if err != nil {
+		return 0, checkTimeout(ctx, req, time.Since(startTime), err)
+	}
+
+	var weatherResp OpenWeatherMapResponse
+	if err := json.Unmarshal(body, &weatherResp); err != nil {
+		return 0, err
+	}
+
+	slog.DebugContext(ctx, "Temperature search finished", "duration", duration)
+
+	return kelvinToCelsius(weatherResp.Main.Temp), nil
+}
+
+// mockWeatherProvider serves a fake temperature derived from the city name,
+// so the whole chain can run offline and repeated runs return the same
+// values. It is selected with WEATHER_PROVIDER=mock.
+type mockWeatherProvider struct{}
+
+func (mockWeatherProvider) Name() string {
+	return "mock"
+}
+
+func (mockWeatherProvider) Temperature(ctx context.Context, city string) (float64, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("weather.mock", true))
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(city)))
+	// Spread the hash over -5.0 to 39.9 °C in tenths of a degree.
+	return float64(h.Sum32()%450)/10 - 5, nil
+}
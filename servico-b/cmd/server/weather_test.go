@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKelvinSourceConversion(t *testing.T) {
+	tempC := kelvinToCelsius(301.65)
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"celsius", roundTo(tempC, 2), 28.5},
+		{"fahrenheit", roundTo(celsiusToFahrenheit(tempC), 2), 83.3},
+		{"kelvin", roundTo(celsiusToKelvin(tempC), 2), 301.65},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenWeatherMapReportsCelsius(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"Recife","main":{"temp":301.65}}`))
+	}))
+	t.Cleanup(server.Close)
+	upstreamClient = newUpstreamClient(0, defaultMaxRedirects, nil)
+
+	provider := openWeatherMapProvider{baseURL: server.URL, apiKey: "test-key"}
+	tempC, err := provider.Temperature(context.Background(), "Recife")
+	if err != nil {
+		t.Fatalf("Temperature: %v", err)
+	}
+	if got := roundTo(tempC, 2); got != 28.5 {
+		t.Errorf("Temperature = %v °C, want 28.5", got)
+	}
+}
+
+func TestWeatherAPIErrorBodyWithStatusOK(t *testing.T) {
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"01310100": {Cep: "01310-100", Localidade: "São Paulo", UF: "SP"},
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"no matching location", `{"error":{"code":1006,"message":"No matching location found."}}`, http.StatusNotFound},
+		{"other error code", `{"error":{"code":9999,"message":"Internal application error."}}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weatherAPI := fakeWeatherAPI(t, http.StatusOK, tt.body)
+			router := newTestRouter(t, testConfig(viaCEP, weatherAPI))
+
+			rec := postTemperature(t, router, "01310100")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusNotFound {
+				return
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body["error"] != ErrLocationNotFound.Error() {
+				t.Errorf("error = %q, want %q", body["error"], ErrLocationNotFound.Error())
+			}
+		})
+	}
+}
+
+func TestOpenWeatherMapUnknownCity(t *testing.T) {
+	viaCEP := fakeViaCEP(t, map[string]ViaCEPResponse{
+		"01310100": {Cep: "01310-100", Localidade: "São Paulo", UF: "SP"},
+	})
+	openWeatherMap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"cod":"404","message":"city not found"}`))
+	}))
+	t.Cleanup(openWeatherMap.Close)
+
+	cfg := testConfig(viaCEP, openWeatherMap)
+	cfg.WeatherProvider = "openweathermap"
+	cfg.OpenWeatherMapBaseURL = openWeatherMap.URL
+	cfg.OpenWeatherMapAPIKey = "test-key"
+	router := newTestRouter(t, cfg)
+
+	provider := openWeatherMapProvider{baseURL: openWeatherMap.URL, apiKey: "test-key"}
+	if _, err := provider.Temperature(context.Background(), "Nowhere"); !errors.Is(err, ErrLocationNotFound) {
+		t.Errorf("Temperature error = %v, want ErrLocationNotFound", err)
+	}
+
+	rec := postTemperature(t, router, "01310100")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusNotFound, rec.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body["error"] != ErrLocationNotFound.Error() {
+		t.Errorf("error = %q, want %q", body["error"], ErrLocationNotFound.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// weatherOptions are the optional WeatherAPI query parameters a client can
+// choose with ?lang= and ?aqi=. The zero value keeps WeatherAPI's defaults:
+// English and no air quality data.
+type weatherOptions struct {
+	lang string
+	aqi  bool
+}
+
+// weatherAPILanguages are the lang codes WeatherAPI accepts besides the
+// default English.
+var weatherAPILanguages = map[string]bool{
+	"ar": true, "bn": true, "bg": true, "zh": true, "zh_tw": true, "cs": true,
+	"da": true, "nl": true, "fi": true, "fr": true, "de": true, "el": true,
+	"hi": true, "hu": true, "it": true, "ja": true, "jv": true, "ko": true,
+	"zh_cmn": true, "mr": true, "pl": true, "pt": true, "pa": true, "ro": true,
+	"ru": true, "sr": true, "si": true, "sk": true, "es": true, "sv": true,
+	"ta": true, "te": true, "tr": true, "uk": true, "ur": true, "vi": true,
+	"zh_wuu": true, "zh_hsn": true, "zh_yue": true, "zu": true,
+}
+
+// parseWeatherOptions reads lang and aqi from the request query, rejecting
+// values WeatherAPI does not support.
+func parseWeatherOptions(query url.Values) (weatherOptions, error) {
+	var opts weatherOptions
+
+	switch lang := query.Get("lang"); {
+	case lang == "", lang == "en":
+	case weatherAPILanguages[lang]:
+		opts.lang = lang
+	default:
+		return weatherOptions{}, fmt.Errorf("unsupported lang %q", lang)
+	}
+
+	switch aqi := query.Get("aqi"); aqi {
+	case "", "no":
+	case "yes":
+		opts.aqi = true
+	default:
+		return weatherOptions{}, fmt.Errorf("aqi must be yes or no, got %q", aqi)
+	}
+
+	return opts, nil
+}
+
+// Lang is the language recorded on spans, "en" when none was chosen.
+func (o weatherOptions) Lang() string {
+	if o.lang == "" {
+		return "en"
+	}
+	return o.lang
+}
+
+type weatherOptionsKey struct{}
+
+func withWeatherOptions(ctx context.Context, opts weatherOptions) context.Context {
+	return context.WithValue(ctx, weatherOptionsKey{}, opts)
+}
+
+// weatherOptionsFrom returns the options of the request in ctx, or the
+// defaults.
+func weatherOptionsFrom(ctx context.Context) weatherOptions {
+	opts, _ := ctx.Value(weatherOptionsKey{}).(weatherOptions)
+	return opts
+}